@@ -21,6 +21,7 @@ import (
 	"strings"
 
 	"github.com/jenkins-x/jx/pkg/cmd"
+	"github.com/jenkins-x/jx/pkg/plugins"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 )
@@ -29,6 +30,10 @@ const descriptionSourcePath = "docs/reference/cmd/"
 
 func generateCliYaml(opts *options) error {
 	root := cmd.Main(nil)
+	if opts.plugins {
+		// lets document the commands of the installed plugins which support the metadata protocol
+		plugins.RegisterPluginCommands(root, true)
+	}
 	disableFlagsInUseLine(root)
 	source := filepath.Join(opts.source, descriptionSourcePath)
 	if err := loadLongDescription(root, source); err != nil {
@@ -94,9 +99,10 @@ func loadLongDescription(cmd *cobra.Command, path ...string) error {
 }
 
 type options struct {
-	source string
-	target string
-	kind   string
+	source  string
+	target  string
+	kind    string
+	plugins bool
 }
 
 func parseArgs() (*options, error) {
@@ -106,6 +112,7 @@ func parseArgs() (*options, error) {
 	flags.StringVar(&opts.source, "root", cwd, "Path to project root")
 	flags.StringVar(&opts.target, "target", "/tmp", "Target path for generated yaml files")
 	flags.StringVar(&opts.kind, "kind", "markdown", "Kind of docs to generate (supported: man, markdown)")
+	flags.BoolVar(&opts.plugins, "plugins", false, "Include the commands of the installed plugins which support the plugin metadata protocol")
 	err := flags.Parse(os.Args[1:])
 	return opts, err
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+)
+
+// Generates the default plugin lock embedded in the jx binary by downloading the archives of
+// every managed plugin for every platform and recording their checksums
+func main() {
+	target := flag.String("target", "pkg/plugins/plugins.lock", "the plugin lock file to generate")
+	flag.Parse()
+
+	lock := &plugins.Lock{Path: *target}
+	for i := range plugins.Plugins {
+		spec := &plugins.Plugins[i].Spec
+		for _, b := range spec.Binaries {
+			platform := strings.ToLower(b.Goos) + "/" + strings.ToLower(b.Goarch)
+			fmt.Printf("downloading %s\n", b.URL)
+			data, err := download(b.URL)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "failed to download %s: %s\n", b.URL, err.Error())
+				os.Exit(1)
+			}
+			lock.SetChecksum(spec.Name, spec.Version, platform, plugins.Checksum(data))
+		}
+	}
+	if err := lock.Save(); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("generated %s\n", *target)
+}
+
+func download(u string) ([]byte, error) {
+	resp, err := http.Get(u) //nolint:gosec
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
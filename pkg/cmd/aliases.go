@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+// addUserAliases adds the aliases defined in the configuration file to the command tree returning the
+// new top level commands. Aliases which conflict with existing commands or would shadow plugins are ignored
+// with a warning
+func addUserAliases(rootCmd *cobra.Command, fn func(cmd *cobra.Command, args []string), aliases []config.Alias) []*cobra.Command {
+	pluginBinDir, err := homedir.DefaultPluginBinDir()
+	if err != nil {
+		log.Logger().Debugf("failed to find plugin bin directory: %s", err.Error())
+	}
+	shadows := newPluginShadowChecker(pluginBinDir)
+
+	var topLevel []*cobra.Command
+	for i := range aliases {
+		a := &aliases[i]
+		err := validateAlias(a)
+		if err != nil {
+			log.Logger().Warnf("ignoring alias: %s", err.Error())
+			continue
+		}
+
+		// lets check the whole alias before creating any commands so that a rejected alias leaves nothing behind
+		parentNames := strings.Fields(a.Parent)
+		err = checkAlias(rootCmd, a, parentNames, shadows)
+		if err != nil {
+			log.Logger().Warnf("ignoring alias %s: %s", a.Name, err.Error())
+			continue
+		}
+
+		parent := rootCmd
+		for _, name := range parentNames {
+			child := findSubCommand(parent, name)
+			if child == nil {
+				child = &cobra.Command{
+					Use:   name,
+					Short: "User defined aliases",
+					Run: func(cmd *cobra.Command, _ []string) {
+						err := cmd.Help()
+						helper.CheckErr(err)
+					},
+				}
+				parent.AddCommand(child)
+				if parent == rootCmd {
+					topLevel = append(topLevel, child)
+				}
+			}
+			parent = child
+		}
+
+		cmd := aliasCommand(rootCmd, fn, a.Name, a.Args, a.Aliases...)
+		if a.Short != "" {
+			cmd.Short = a.Short
+		}
+		parent.AddCommand(cmd)
+		if parent == rootCmd {
+			topLevel = append(topLevel, cmd)
+		}
+	}
+	return topLevel
+}
+
+// checkAlias returns an error if the alias conflicts with an existing command or if any command it would create
+// would shadow a plugin such as a parent of gitops hiding the jx-gitops plugin
+func checkAlias(rootCmd *cobra.Command, a *config.Alias, parentNames []string, shadows func(path []string) string) error {
+	parent := rootCmd
+	var path []string
+	for _, name := range parentNames {
+		path = append(path, name)
+		if parent != nil {
+			parent = findSubCommand(parent, name)
+			if parent != nil {
+				continue
+			}
+		}
+		if plugin := shadows(path); plugin != "" {
+			return fmt.Errorf("its parent %s would shadow the plugin %s", strings.Join(path, " "), plugin)
+		}
+	}
+	for _, name := range append([]string{a.Name}, a.Aliases...) {
+		if parent != nil {
+			if existing := findSubCommand(parent, name); existing != nil {
+				return fmt.Errorf("it conflicts with the existing command: %s", existing.CommandPath())
+			}
+		}
+		if plugin := shadows(append(append([]string{}, path...), name)); plugin != "" {
+			return fmt.Errorf("%s would shadow the plugin %s", name, plugin)
+		}
+	}
+	return nil
+}
+
+// newPluginShadowChecker returns a function which returns the name of the plugin which a new command with the
+// given path would shadow or an empty string if there is none.
+//
+// A command shadows the plugin with the same name along with any plugins for its sub commands as plugins are only
+// looked up for commands which are not in the command tree
+func newPluginShadowChecker(pluginBinDir string) func(path []string) string {
+	var known []string
+	for name := range plugins.PluginMap {
+		known = append(known, name)
+	}
+	sources, err := plugins.LoadSources()
+	if err != nil {
+		log.Logger().Debugf("failed to load plugin sources: %s", err.Error())
+	}
+	known = append(known, sources.Names()...)
+	var searchPath *plugins.SearchPath
+	if pluginBinDir != "" {
+		searchPath = plugins.NewSearchPath(pluginBinDir)
+		installed, _ := plugins.InstalledPlugins(pluginBinDir)
+		for name := range installed {
+			known = append(known, name)
+		}
+	}
+	sort.Strings(known)
+
+	return func(path []string) string {
+		var words []string
+		for _, name := range path {
+			words = append(words, strings.ReplaceAll(name, "-", "_"))
+		}
+		binaryName := plugins.BinaryName(strings.Join(words, "-"))
+		for _, name := range known {
+			if name == binaryName || strings.HasPrefix(name, binaryName+"-") {
+				return name
+			}
+		}
+		if searchPath != nil && searchPath.Find(binaryName) != nil {
+			return binaryName
+		}
+		return ""
+	}
+}
+
+// validateAlias validates the alias from the configuration file
+func validateAlias(a *config.Alias) error {
+	if a.Name == "" || strings.ContainsAny(a.Name, " \t") {
+		return fmt.Errorf("invalid alias name %q", a.Name)
+	}
+	if len(a.Args) == 0 {
+		return fmt.Errorf("alias %s has no args", a.Name)
+	}
+	return nil
+}
+
+// findSubCommand returns the sub command of the parent with the given name or alias
+func findSubCommand(parent *cobra.Command, name string) *cobra.Command {
+	for _, c := range parent.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/history"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeExecutor struct {
+	binary      string
+	args        []string
+	environment []string
+	exitCode    int
+}
+
+func (e *fakeExecutor) Execute(binary string, args, environment []string) (int, error) {
+	e.binary = binary
+	e.args = args
+	e.environment = environment
+	return e.exitCode, nil
+}
+
+func TestHandleEndpointExtensions(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("JX3_HOME", home)
+	t.Setenv("JX_TEST_SECRET", "abc")
+
+	// plugins should still be invoked if the configuration is invalid as Main only warns about it
+	path := filepath.Join(home, "config.yaml")
+	err := os.WriteFile(path, []byte("aliases: [\n"), 0o600)
+	require.NoError(t, err)
+	t.Setenv(config.FileEnvVar, path)
+
+	pluginBinDir := filepath.Join(home, "plugins", "bin")
+	err = os.MkdirAll(pluginBinDir, 0o755)
+	require.NoError(t, err)
+	binary := filepath.Join(pluginBinDir, "jx-doesnotexist-1.2.3")
+	err = os.WriteFile(binary, []byte("#!/bin/sh\n"), 0o700) //nolint:gosec
+	require.NoError(t, err)
+
+	recorder := &history.Recorder{Path: filepath.Join(home, history.FileName)}
+	executor := &fakeExecutor{exitCode: 3}
+	found, exitCode, err := handleEndpointExtensions([]string{"doesnotexist", "--name", "thingy"}, pluginBinDir, &config.Config{}, recorder, executor)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 3, exitCode)
+	assert.Equal(t, binary, executor.binary)
+	assert.Equal(t, []string{"--name", "thingy"}, executor.args)
+	assert.NotContains(t, executor.environment, "JX_TEST_SECRET=abc", "should not pass secrets to unmanaged plugins")
+
+	entries, err := history.Load(recorder.Path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "jx-doesnotexist", entries[0].Plugin)
+	assert.Equal(t, "1.2.3", entries[0].PluginVersion)
+	assert.Equal(t, 3, entries[0].ExitCode)
+}
+
+func TestHandleEndpointExtensionsPrefersLocalPlugins(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, nil)
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	path := filepath.Join(home, "config.yaml")
+	err := os.WriteFile(path, []byte("pluginIndexes:\n- name: platform\n  url: "+server.URL+"/plugins.yaml\n"), 0o600)
+	require.NoError(t, err)
+	t.Setenv("JX3_HOME", home)
+	t.Setenv(config.FileEnvVar, path)
+
+	pluginBinDir := filepath.Join(home, "plugins", "bin")
+	err = os.MkdirAll(pluginBinDir, 0o755)
+	require.NoError(t, err)
+	binary := filepath.Join(pluginBinDir, "jx-doesnotexist-1.2.3")
+	err = os.WriteFile(binary, []byte("#!/bin/sh\n"), 0o700) //nolint:gosec
+	require.NoError(t, err)
+
+	recorder := &history.Recorder{}
+	executor := &fakeExecutor{}
+	found, _, err := handleEndpointExtensions([]string{"doesnotexist", "myapp", "1.2.3"}, pluginBinDir, &config.Config{}, recorder, executor)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, binary, executor.binary)
+	assert.Equal(t, []string{"myapp", "1.2.3"}, executor.args)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests), "should not load the plugin indexes")
+
+	cacheDir, err := config.CacheDir()
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(cacheDir, "unknown-plugins.json"), "should not remember the positional arguments")
+}
+
+func TestApplyOfflineFlag(t *testing.T) {
+	t.Setenv(plugins.OfflineEnvVar, "")
+
+	args := applyOfflineFlag([]string{"gitops", "get", "--offline"})
+	assert.Equal(t, []string{"gitops", "get", "--offline"}, args, "should pass flags after the command to the plugin")
+	assert.False(t, plugins.IsOffline())
+
+	args = applyOfflineFlag([]string{"--offline", "gitops", "get"})
+	assert.Equal(t, []string{"gitops", "get"}, args)
+	assert.True(t, plugins.IsOffline())
+}
+
+func TestReportUnknownCommand(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, "config.yaml")
+	err := os.WriteFile(path, []byte(`aliases:
+- name: envs
+  parent: get
+  args: [gitops, get, environments]
+pluginIndexes:
+- name: platform
+  url: https://example.com/plugins.yaml
+`), 0o600)
+	require.NoError(t, err)
+
+	// the cached copy of the plugin index is used without the network
+	cachedIndex := filepath.Join(home, "cache", "indexes", "platform.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(cachedIndex), 0o700))
+	err = os.WriteFile(cachedIndex, []byte(`- apiVersion: jenkins.io/v1
+  kind: Plugin
+  metadata:
+    name: mytool
+  spec:
+    name: jx-mytool
+    version: 1.2.3
+    binaries:
+    - goarch: amd64
+      goos: Linux
+      url: https://example.com/jx-mytool-linux-amd64.tar.gz
+`), 0o600)
+	require.NoError(t, err)
+	t.Setenv("JX3_HOME", home)
+	t.Setenv(config.FileEnvVar, path)
+	t.Setenv(plugins.PluginPathEnvVar, t.TempDir())
+
+	testCases := map[string]string{
+		"gitop":        "jx gitops",
+		"get evns":     "jx get envs",
+		"verison":      "jx version",
+		"pipelne logs": "jx pipeline",
+		"mytol":        "jx mytool",
+	}
+	for args, expected := range testCases {
+		var out bytes.Buffer
+		reportUnknownCommand(&out, Main([]string{"jx"}), strings.Fields(args))
+		text := out.String()
+		assert.Contains(t, text, `unknown command "`+args+`" for "jx"`)
+		assert.Contains(t, text, "Did you mean this?")
+		assert.Contains(t, text, "\t"+expected+"\n", "for %s", args)
+	}
+
+	var out bytes.Buffer
+	reportUnknownCommand(&out, Main([]string{"jx"}), []string{"doesnotexist", "--name", "thingy"})
+	assert.Contains(t, out.String(), `unknown command "doesnotexist" for "jx"`)
+	assert.NotContains(t, out.String(), "Did you mean this?")
+}
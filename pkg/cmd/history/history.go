@@ -0,0 +1,202 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/history"
+	"github.com/spf13/cobra"
+)
+
+const defaultLimit = 20
+
+var (
+	cmdLong = templates.LongDesc(`
+		Displays the jx commands and plugins you have run along with how long they took and their exit code.
+
+		Any arguments which look like secrets are redacted before they are recorded. The history is stored in the
+		jx home dir and can be disabled via the history.disabled setting in the jx configuration file or by
+		setting $JX_NO_HISTORY to true.
+
+		Plugins replace the jx process by default so their duration and exit code are only recorded if the
+		history.timing setting is enabled, which runs plugins as child processes of jx.
+`)
+
+	cmdExample = templates.Examples(`
+		# displays the most recent commands
+		jx history
+
+		# displays the commands which mention promote
+		jx history promote
+
+		# displays the failed gitops commands run in the last week
+		jx history --plugin gitops --failed --since 168h
+
+		# displays the history as JSON
+		jx history --json --limit 0
+
+		# re-runs a command from the history
+		jx history --rerun 42
+	`)
+)
+
+// Options the options for the command
+type Options struct {
+	Filter  []string
+	Plugin  string
+	Failed  bool
+	Since   time.Duration
+	Limit   int
+	JSON    bool
+	Rerun   int
+	File    string
+	Out     io.Writer
+	Command func(entry *history.Entry) *exec.Cmd
+}
+
+// NewCmdHistory creates a command object for the "history" command
+func NewCmdHistory() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	cmd := &cobra.Command{
+		Use:     "history [text]",
+		Short:   "Displays the commands which have been run",
+		Aliases: []string{"hist"},
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, args []string) {
+			o.Filter = args
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Plugin, "plugin", "p", "", "only displays the commands which invoked the given plugin such as gitops")
+	cmd.Flags().BoolVarP(&o.Failed, "failed", "", false, "only displays the commands which failed")
+	cmd.Flags().DurationVarP(&o.Since, "since", "", 0, "only displays the commands run within the given duration such as 24h")
+	cmd.Flags().IntVarP(&o.Limit, "limit", "n", defaultLimit, "the maximum number of the most recent commands to display or 0 for all")
+	cmd.Flags().BoolVarP(&o.JSON, "json", "", false, "displays the history as JSON")
+	cmd.Flags().IntVarP(&o.Rerun, "rerun", "", 0, "re-runs the command with the given ID")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *Options) Run() error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.File == "" {
+		var err error
+		o.File, err = history.File()
+		if err != nil {
+			return err
+		}
+	}
+	entries, err := history.Load(o.File)
+	if err != nil {
+		return fmt.Errorf("failed to load history: %w", err)
+	}
+	if o.Rerun > 0 {
+		return o.rerun(entries)
+	}
+
+	entries = o.Matches(entries, time.Now())
+	if o.JSON {
+		if entries == nil {
+			entries = []*history.Entry{}
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal to JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(o.Out, string(data))
+		return err
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0) //nolint:mnd
+	fmt.Fprintln(w, "ID\tTIME\tDURATION\tEXIT\tCOMMAND")
+	for _, e := range entries {
+		duration, exitCode := e.Duration.Round(time.Millisecond).String(), strconv.Itoa(e.ExitCode)
+		if e.Exec {
+			duration, exitCode = "-", "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", e.ID, e.Time.Local().Format(time.DateTime), duration, exitCode, commandLine(e))
+	}
+	return w.Flush()
+}
+
+// Matches returns the most recent entries matching the filters, oldest first
+func (o *Options) Matches(entries []*history.Entry, now time.Time) []*history.Entry {
+	plugin := ""
+	if o.Plugin != "" {
+		plugin = "jx-" + strings.TrimPrefix(o.Plugin, "jx-")
+	}
+	text := strings.Join(o.Filter, " ")
+
+	var answer []*history.Entry
+	for _, e := range entries {
+		if plugin != "" && e.Plugin != plugin {
+			continue
+		}
+		if o.Failed && e.ExitCode == 0 {
+			continue
+		}
+		if o.Since > 0 && e.Time.Before(now.Add(-o.Since)) {
+			continue
+		}
+		if text != "" && !strings.Contains(strings.Join(e.Args, " "), text) {
+			continue
+		}
+		answer = append(answer, e)
+	}
+	if o.Limit > 0 && len(answer) > o.Limit {
+		answer = answer[len(answer)-o.Limit:]
+	}
+	return answer
+}
+
+func (o *Options) rerun(entries []*history.Entry) error {
+	entry := history.Find(entries, o.Rerun)
+	if entry == nil {
+		return fmt.Errorf("there is no command with ID %d in the history", o.Rerun)
+	}
+	if slices.ContainsFunc(entry.Args, func(arg string) bool {
+		return strings.Contains(arg, history.Redacted)
+	}) {
+		return fmt.Errorf("cannot re-run command %d as some of its arguments were redacted: %s", entry.ID, commandLine(entry))
+	}
+	if o.Command == nil {
+		o.Command = defaultCommand
+	}
+	c := o.Command(entry)
+	log.Logger().Infof("running: %s in %s", termcolor.ColorInfo(commandLine(entry)), entry.Dir)
+	return c.Run()
+}
+
+func defaultCommand(entry *history.Entry) *exec.Cmd {
+	bin, err := os.Executable()
+	if err != nil {
+		bin = os.Args[0]
+	}
+	c := exec.Command(bin, entry.Args...)
+	c.Dir = entry.Dir
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	return c
+}
+
+func commandLine(e *history.Entry) string {
+	return strings.Join(append([]string{"jx"}, e.Args...), " ")
+}
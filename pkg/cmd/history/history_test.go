@@ -0,0 +1,99 @@
+package history_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	cmdhistory "github.com/jenkins-x/jx/pkg/cmd/history"
+	"github.com/jenkins-x/jx/pkg/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createHistory(t *testing.T, entries ...*history.Entry) string {
+	path := filepath.Join(t.TempDir(), history.FileName)
+	for _, e := range entries {
+		err := history.Append(path, e, history.DefaultMaxSize)
+		require.NoError(t, err)
+	}
+	return path
+}
+
+func TestHistoryFilters(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	path := createHistory(t,
+		&history.Entry{Time: now.Add(-48 * time.Hour), Args: []string{"gitops", "get"}, Plugin: "jx-gitops"},
+		&history.Entry{Time: now.Add(-time.Hour), Args: []string{"promote", "-a", "app"}, Plugin: "jx-promote", ExitCode: 1},
+		&history.Entry{Time: now, Args: []string{"gitops", "upgrade"}, Plugin: "jx-gitops", ExitCode: 2},
+	)
+
+	ids := func(o *cmdhistory.Options) []int {
+		buf := &bytes.Buffer{}
+		o.File = path
+		o.JSON = true
+		o.Out = buf
+		err := o.Run()
+		require.NoError(t, err)
+
+		var entries []*history.Entry
+		err = json.Unmarshal(buf.Bytes(), &entries)
+		require.NoError(t, err, "failed to parse %s", buf.String())
+		var answer []int
+		for _, e := range entries {
+			answer = append(answer, e.ID)
+		}
+		return answer
+	}
+
+	assert.Equal(t, []int{1, 2, 3}, ids(&cmdhistory.Options{}))
+	assert.Equal(t, []int{2, 3}, ids(&cmdhistory.Options{Limit: 2}))
+	assert.Equal(t, []int{1, 3}, ids(&cmdhistory.Options{Plugin: "gitops"}))
+	assert.Equal(t, []int{2, 3}, ids(&cmdhistory.Options{Failed: true}))
+	assert.Equal(t, []int{2, 3}, ids(&cmdhistory.Options{Since: 24 * time.Hour}))
+	assert.Equal(t, []int{2}, ids(&cmdhistory.Options{Filter: []string{"promote"}}))
+	assert.Nil(t, ids(&cmdhistory.Options{Filter: []string{"doesnotexist"}}))
+
+	buf := &bytes.Buffer{}
+	o := &cmdhistory.Options{File: path, Out: buf}
+	err := o.Run()
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "jx promote -a app")
+}
+
+func TestHistoryRerun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := createHistory(t,
+		&history.Entry{Args: []string{"gitops", "get"}, Dir: dir},
+		&history.Entry{Args: []string{"admin", "--token", history.Redacted}, Dir: dir},
+	)
+
+	var rerun *history.Entry
+	o := &cmdhistory.Options{
+		File:  path,
+		Rerun: 1,
+		Command: func(entry *history.Entry) *exec.Cmd {
+			rerun = entry
+			return exec.Command("true")
+		},
+	}
+	err := o.Run()
+	require.NoError(t, err)
+	require.NotNil(t, rerun)
+	assert.Equal(t, []string{"gitops", "get"}, rerun.Args)
+
+	o.Rerun = 2
+	err = o.Run()
+	assert.Error(t, err, "should not re-run redacted commands")
+
+	o.Rerun = 3
+	err = o.Run()
+	assert.Error(t, err, "should fail for a missing ID")
+}
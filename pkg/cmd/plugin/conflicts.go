@@ -0,0 +1,106 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdConflictsLong = templates.LongDesc(`
+		Reports the plugins which have binaries in more than one directory of the plugin search path along with
+		which binary is used and which are shadowed.
+
+		The search path is configured via $JX_PLUGIN_PATH and defaults to the PATH followed by the plugin directory.
+`)
+
+	cmdConflictsExample = templates.Examples(`
+		# reports the shadowed and duplicate plugins
+		jx plugin conflicts
+
+		# reports the shadowed and duplicate plugins as JSON
+		jx plugin conflicts -o json
+	`)
+)
+
+// ConflictsOptions the options for reporting conflicting plugins
+type ConflictsOptions struct {
+	PluginBinDir string
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdPluginConflicts creates a command object for reporting conflicting plugins
+func NewCmdPluginConflicts() (*cobra.Command, *ConflictsOptions) {
+	o := &ConflictsOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "conflicts",
+		Short:   "Reports the plugins which are shadowed by or duplicated in other directories of the plugin search path",
+		Aliases: []string{"conflict", "shadowed"},
+		Long:    cmdConflictsLong,
+		Example: cmdConflictsExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *ConflictsOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	conflicts, err := plugins.NewSearchPath(o.PluginBinDir).Conflicts()
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		if conflicts == nil {
+			conflicts = []*plugins.PluginConflict{}
+		}
+		return writeJSON(o.Out, conflicts)
+	}
+	if len(conflicts) == 0 {
+		fmt.Fprintln(o.Out, "no conflicting plugins found")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0) //nolint:mnd
+	fmt.Fprintln(w, "NAME\tMANAGED\tUSED\tSHADOWED")
+	for _, c := range conflicts {
+		used := "(pinned version not installed)"
+		if c.Used != nil {
+			used = c.Used.Path
+		}
+		for i, l := range c.Shadowed {
+			if i == 0 {
+				fmt.Fprintf(w, "%s\t%t\t%s\t%s\n", c.Binary, c.Managed, used, l.Path)
+			} else {
+				fmt.Fprintf(w, "\t\t\t%s\n", l.Path)
+			}
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,120 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdInfoLong = templates.LongDesc(`
+		Displays the details of a plugin such as its pinned version, the installed versions and
+		which binary is used when invoking it.
+`)
+
+	cmdInfoExample = templates.Examples(`
+		# views the details of the gitops plugin
+		jx plugin info gitops
+
+		# views the details of the gitops plugin as JSON
+		jx plugin info gitops -o json
+	`)
+)
+
+// InfoOptions the options for viewing a plugin
+type InfoOptions struct {
+	PluginBinDir string
+	Name         string
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdPluginInfo creates a command object for viewing a plugin
+func NewCmdPluginInfo() (*cobra.Command, *InfoOptions) {
+	o := &InfoOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "info NAME",
+		Short:             "Displays the details of a plugin",
+		Long:              cmdInfoLong,
+		Example:           cmdInfoExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePluginNames,
+		Run: func(_ *cobra.Command, args []string) {
+			o.Name = args[0]
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *InfoOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	p, err := plugins.GetPluginInfo(o.PluginBinDir, o.Name)
+	if err != nil {
+		return err
+	}
+	if !p.Managed && len(p.InstalledVersions) == 0 && p.PathBinary == "" {
+		return fmt.Errorf("plugin %s is not managed or installed", o.Name)
+	}
+	if o.Output == "json" {
+		return writeJSON(o.Out, p)
+	}
+
+	fmt.Fprintf(o.Out, "name: %s\n", p.Name)
+	fmt.Fprintf(o.Out, "binary: %s\n", p.Binary)
+	fmt.Fprintf(o.Out, "managed: %t\n", p.Managed)
+	if p.Version != "" {
+		fmt.Fprintf(o.Out, "version: %s\n", p.Version)
+	}
+	if p.Description != "" {
+		fmt.Fprintf(o.Out, "description: %s\n", p.Description)
+	}
+	fmt.Fprintf(o.Out, "installedVersions: %s\n", strings.Join(p.InstalledVersions, ", "))
+	fmt.Fprintf(o.Out, "path: %s\n", p.Path)
+	if p.PathBinary != "" {
+		fmt.Fprintf(o.Out, "pathBinary: %s\n", p.PathBinary)
+	}
+	fmt.Fprintf(o.Out, "shadowed: %t\n", p.Shadowed)
+	if p.Source != "" {
+		fmt.Fprintf(o.Out, "source: %s\n", p.Source)
+	}
+	return nil
+}
+
+// completePluginNames completes the names of the managed and installed plugins
+func completePluginNames(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var names []string
+	for _, name := range plugins.AllPlugins() {
+		if strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdInstallLong = templates.LongDesc(`
+		Installs a plugin from the plugin indexes or from the releases of a GitHub or GitLab repository into the
+		plugin directory so that it can be invoked via jx.
+
+		The plugin is looked up in the managed plugins of this version of jx followed by the configured
+		plugin indexes in order. Use --index to install the plugin from a specific index.
+		See 'jx plugin search --help' for how to configure plugin indexes.
+
+		A repository such as github.com/myorg/jx-mytool installs the plugin jx-mytool from the latest release or
+		the release of the version after the @. If the release has a plugin.yaml asset containing a jenkins.io/v1
+		Plugin resource its binaries are used, otherwise the binary of the current platform is found by the
+		asset names such as jx-mytool-linux-amd64.tar.gz. The repository is recorded so that 'jx upgrade plugins'
+		upgrades the plugin from its latest release.
+`)
+
+	cmdInstallExample = templates.Examples(`
+		# installs the plugin jx-mytool from the first index containing it
+		jx plugin install mytool
+
+		# installs the plugin from a specific index
+		jx plugin install mytool --index platform
+
+		# installs the latest release of a plugin from a GitHub repository
+		jx plugin install github.com/myorg/jx-mytool
+
+		# installs a specific version of a plugin from a GitLab repository
+		jx plugin install gitlab.com/mygroup/jx-mytool@1.2.3
+	`)
+)
+
+// InstallOptions the options for installing a plugin from the plugin indexes or a repository
+type InstallOptions struct {
+	Name               string
+	Index              string
+	Refresh            bool
+	InsecureSkipVerify bool
+	PluginBinDir       string
+	Loader             *plugins.IndexLoader
+	Installer          *plugins.Installer
+	Sources            *plugins.Sources
+	Out                io.Writer
+}
+
+// NewCmdPluginInstall creates a command object for installing a plugin from the plugin indexes or a repository
+func NewCmdPluginInstall() (*cobra.Command, *InstallOptions) {
+	o := &InstallOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "install <name>|<host>/<owner>/<repo>[@version]",
+		Short:   "Installs a plugin from the plugin indexes or a GitHub or GitLab repository",
+		Long:    cmdInstallLong,
+		Example: cmdInstallExample,
+		Args:    cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			o.Name = args[0]
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Index, "index", "i", "", "the name of the plugin index to install the plugin from")
+	cmd.Flags().BoolVarP(&o.Refresh, "refresh", "", false, "fetches the plugin indexes even if the cached copies have not expired")
+	cmd.Flags().BoolVarP(&o.InsecureSkipVerify, "insecure-skip-verify", "", false, "disables the verification of the signature of the downloaded plugin")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *InstallOptions) Run() error {
+	var err error
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+	if plugins.IsPluginSource(o.Name) {
+		return o.installFromSource()
+	}
+	if o.Loader == nil {
+		o.Loader, err = plugins.NewIndexLoader()
+		if err != nil {
+			return err
+		}
+	}
+	o.Loader.Refresh = o.Refresh
+
+	p := plugins.FindIndexPlugin(o.Loader.Load(), o.Name, o.Index)
+	if p == nil {
+		if o.Index != "" {
+			return fmt.Errorf("plugin %s not found in plugin index %s", o.Name, o.Index)
+		}
+		return fmt.Errorf("plugin %s not found in the plugin indexes. Try: jx plugin search %s", o.Name, o.Name)
+	}
+
+	spec := &p.Plugin.Spec
+	path, err := plugins.NewSearchPath(o.PluginBinDir).EnsurePluginInstalled(p.Plugin)
+	if err != nil {
+		return fmt.Errorf("failed to install plugin %s version %s from index %s: %w", spec.Name, spec.Version, p.Index, err)
+	}
+	fmt.Fprintf(o.Out, "installed plugin %s version %s from index %s to %s\n", termcolor.ColorInfo(spec.Name), termcolor.ColorInfo(spec.Version), p.Index, path)
+	return nil
+}
+
+// installFromSource installs the plugin from the releases of a repository and records the repository
+func (o *InstallOptions) installFromSource() error {
+	src, version, err := plugins.ParsePluginSource(o.Name)
+	if err != nil {
+		return err
+	}
+	if o.Installer == nil {
+		o.Installer, err = plugins.NewInstaller()
+		if err != nil {
+			return fmt.Errorf("failed to create plugin installer: %w", err)
+		}
+	}
+	if o.InsecureSkipVerify {
+		o.Installer.SkipVerify = true
+	}
+	if o.Sources == nil {
+		o.Sources, err = plugins.LoadSources()
+		if err != nil {
+			return err
+		}
+	}
+
+	p, err := o.Installer.ResolveSource(src, version)
+	if err != nil {
+		return err
+	}
+	spec := &p.Spec
+	if plugins.PluginMap[spec.Name] != nil {
+		return fmt.Errorf("cannot install %s from %s as its version is managed by jx", spec.Name, src.String())
+	}
+	path, err := o.Installer.EnsurePluginInstalled(*p, o.PluginBinDir)
+	if err != nil {
+		return fmt.Errorf("failed to install plugin %s version %s from %s: %w", spec.Name, spec.Version, src.String(), err)
+	}
+
+	// lets remember to skip verification when the plugin is upgraded or reinstalled too
+	o.Sources.Set(spec.Name, src, o.InsecureSkipVerify)
+	err = o.Sources.Save()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(o.Out, "installed plugin %s version %s from %s to %s\n", termcolor.ColorInfo(spec.Name), termcolor.ColorInfo(spec.Version), src.String(), path)
+	return nil
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdListLong = templates.LongDesc(`
+		Lists the managed plugins whose versions are pinned by this version of jx along with the
+		plugins installed in the plugin directory.
+`)
+
+	cmdListExample = templates.Examples(`
+		# lists the plugins
+		jx plugin list
+
+		# lists the plugins as JSON
+		jx plugin list -o json
+	`)
+)
+
+// ListOptions the options for listing plugins
+type ListOptions struct {
+	PluginBinDir string
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdPluginList creates a command object for listing plugins
+func NewCmdPluginList() (*cobra.Command, *ListOptions) {
+	o := &ListOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "list",
+		Short:   "Lists the managed and installed plugins",
+		Aliases: []string{"ls"},
+		Long:    cmdListLong,
+		Example: cmdListExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *ListOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	list, err := plugins.ListPlugins(o.PluginBinDir)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return writeJSON(o.Out, list)
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0) //nolint:mnd
+	fmt.Fprintln(w, "NAME\tMANAGED\tVERSION\tINSTALLED\tPATH")
+	for _, p := range list {
+		path := p.Path
+		if p.Shadowed {
+			path += " (shadows the installed versions)"
+		}
+		fmt.Fprintf(w, "%s\t%t\t%s\t%s\t%s\n", p.Name, p.Managed, p.Version, strings.Join(p.InstalledVersions, ","), path)
+	}
+	return w.Flush()
+}
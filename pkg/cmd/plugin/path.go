@@ -0,0 +1,91 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdPathLong = templates.LongDesc(`
+		Displays the directories plugins are looked up in, in order of precedence.
+
+		The search path is configured via $JX_PLUGIN_PATH using the OS path list separator such as:
+
+			export JX_PLUGIN_PATH=/opt/jx/plugins:/team/plugins:$HOME/.jx3/plugins/bin:$PATH
+
+		If it is not set plugins are looked up on the PATH followed by the plugin directory. The plugin directory
+		is always searched as plugins are installed into it. The pinned versions of managed plugins are used from
+		any directory in the search path before they are downloaded.
+
+		Every directory in the search path is trusted like the PATH: binaries found there are not verified
+		against the plugin lock or signatures, which only apply to the plugins jx downloads. Only add
+		directories which are writable by trusted users.
+`)
+
+	cmdPathExample = templates.Examples(`
+		# displays the plugin search path
+		jx plugin path
+	`)
+)
+
+// PathOptions the options for displaying the plugin search path
+type PathOptions struct {
+	PluginBinDir string
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdPluginPath creates a command object for displaying the plugin search path
+func NewCmdPluginPath() (*cobra.Command, *PathOptions) {
+	o := &PathOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "path",
+		Short:   "Displays the directories plugins are looked up in, in order of precedence",
+		Long:    cmdPathLong,
+		Example: cmdPathExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *PathOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	searchPath := plugins.NewSearchPath(o.PluginBinDir)
+	if o.Output == "json" {
+		return writeJSON(o.Out, searchPath.Dirs)
+	}
+	for _, dir := range searchPath.Dirs {
+		if dir == filepath.Clean(o.PluginBinDir) {
+			dir += " (plugin directory)"
+		}
+		fmt.Fprintln(o.Out, dir)
+	}
+	return nil
+}
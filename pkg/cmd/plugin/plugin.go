@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdLong = templates.LongDesc(`
+		Commands for working with the binary plugins of your local JayeX CLI
+`)
+
+	cmdExample = templates.Examples(`
+		# lists the plugins
+		jx plugin list
+
+		# views details of a plugin
+		jx plugin info gitops
+
+		# searches the plugin indexes
+		jx plugin search secret
+	`)
+)
+
+// Options the options for the plugin command
+type Options struct {
+	Cmd *cobra.Command
+}
+
+// NewCmdPlugin creates a command object for the command
+func NewCmdPlugin() (*cobra.Command, *Options) {
+	o := &Options{}
+
+	o.Cmd = &cobra.Command{
+		Use:     "plugin",
+		Short:   "Commands for working with plugins",
+		Aliases: []string{"plugins"},
+		Long:    cmdLong,
+		Example: cmdExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginList()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginInfo()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginPath()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginPrune()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginRemove()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginWhich()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginConflicts()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginSearch()))
+	o.Cmd.AddCommand(cobras.SplitCommand(NewCmdPluginInstall()))
+
+	return o.Cmd, o
+}
+
+// Run implements this command
+func (o *Options) Run() error {
+	return o.Cmd.Help()
+}
+
+// writeJSON writes the value as indented JSON
+func writeJSON(out io.Writer, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal to JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
+
+// validateOutput validates the output format
+func validateOutput(output string) error {
+	if output != "" && output != "json" {
+		return fmt.Errorf("unsupported output format %s, supported values: json", output)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package plugin_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/cmd/plugin"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createPluginBinDir(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o600)
+		require.NoError(t, err, "failed to create %s", name)
+	}
+	return dir
+}
+
+func TestPluginListJSON(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinDir(t, "jx-doesnotexist-1.0.0")
+	buf := &bytes.Buffer{}
+	_, o := plugin.NewCmdPluginList()
+	o.PluginBinDir = dir
+	o.Output = "json"
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err)
+
+	var list []*plugins.PluginInfo
+	err = json.Unmarshal(buf.Bytes(), &list)
+	require.NoError(t, err, "failed to parse %s", buf.String())
+	require.Len(t, list, len(plugins.Plugins)+1)
+	last := list[len(list)-1]
+	assert.Equal(t, "doesnotexist", last.Name)
+	assert.False(t, last.Managed)
+	assert.Equal(t, []string{"1.0.0"}, last.InstalledVersions)
+}
+
+func TestPluginRemove(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinDir(t, "jx-doesnotexist-1.0.0", "jx-doesnotexist-2.0.0")
+
+	_, o := plugin.NewCmdPluginRemove()
+	o.PluginBinDir = dir
+	o.Name = "doesnotexist"
+	o.Version = "1.0.0"
+	err := o.Run()
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "jx-doesnotexist-1.0.0"))
+	assert.FileExists(t, filepath.Join(dir, "jx-doesnotexist-2.0.0"))
+
+	o.Version = ""
+	err = o.Run()
+	require.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(dir, "jx-doesnotexist-2.0.0"))
+
+	err = o.Run()
+	assert.Error(t, err, "should fail when the plugin is not installed")
+}
+
+func TestPluginWhich(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinDir(t, "jx-doesnotexist-1.0.0")
+	buf := &bytes.Buffer{}
+	_, o := plugin.NewCmdPluginWhich()
+	o.PluginBinDir = dir
+	o.Name = "doesnotexist"
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "jx-doesnotexist-1.0.0")+"\n", buf.String())
+}
+
+func TestPluginConflicts(t *testing.T) {
+	teamDir := t.TempDir()
+	t.Setenv(plugins.PluginPathEnvVar, teamDir)
+	err := os.WriteFile(filepath.Join(teamDir, "jx-doesnotexist"), []byte("#!/bin/sh\n"), 0o700) //nolint:gosec
+	require.NoError(t, err)
+	dir := createPluginBinDir(t, "jx-doesnotexist-1.0.0")
+
+	buf := &bytes.Buffer{}
+	_, o := plugin.NewCmdPluginConflicts()
+	o.PluginBinDir = dir
+	o.Output = "json"
+	o.Out = buf
+	err = o.Run()
+	require.NoError(t, err)
+
+	var conflicts []*plugins.PluginConflict
+	err = json.Unmarshal(buf.Bytes(), &conflicts)
+	require.NoError(t, err, "failed to parse %s", buf.String())
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, filepath.Join(teamDir, "jx-doesnotexist"), conflicts[0].Used.Path)
+	require.Len(t, conflicts[0].Shadowed, 1)
+	assert.Equal(t, filepath.Join(dir, "jx-doesnotexist-1.0.0"), conflicts[0].Shadowed[0].Path)
+}
+
+func newTestIndexLoader(t *testing.T) *plugins.IndexLoader {
+	dir := t.TempDir()
+	index := filepath.Join(dir, "plugins.yaml")
+	err := os.WriteFile(index, []byte(`- metadata:
+    name: mytool
+  spec:
+    name: jx-mytool
+    version: 1.2.3
+    description: deploys things for the platform team
+    binaries:
+    - url: https://example.com/jx-mytool.tar.gz
+`), 0o600)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(dir)))
+	t.Cleanup(server.Close)
+	return &plugins.IndexLoader{
+		Indexes:  []config.PluginIndex{{Name: "platform", URL: server.URL + "/plugins.yaml"}},
+		CacheDir: t.TempDir(),
+		Client:   server.Client(),
+	}
+}
+
+func TestPluginSearchJSON(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	_, o := plugin.NewCmdPluginSearch()
+	o.Term = "platform"
+	o.Loader = newTestIndexLoader(t)
+	o.Output = "json"
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err)
+
+	var results []*plugin.SearchResult
+	err = json.Unmarshal(buf.Bytes(), &results)
+	require.NoError(t, err, "failed to parse %s", buf.String())
+	require.Len(t, results, 1)
+	assert.Equal(t, plugin.SearchResult{
+		Name:        "mytool",
+		Binary:      "jx-mytool",
+		Version:     "1.2.3",
+		Index:       "platform",
+		Description: "deploys things for the platform team",
+	}, *results[0])
+}
+
+func TestPluginInstall(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinDir(t, "jx-mytool-1.2.3")
+	buf := &bytes.Buffer{}
+	_, o := plugin.NewCmdPluginInstall()
+	o.Name = "mytool"
+	o.PluginBinDir = dir
+	o.Loader = newTestIndexLoader(t)
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), filepath.Join(dir, "jx-mytool-1.2.3"))
+
+	o.Index = plugins.ManagedIndexName
+	err = o.Run()
+	assert.Error(t, err, "should not find mytool in the managed plugins")
+}
+
+func TestPluginInstallFromSource(t *testing.T) {
+	t.Parallel()
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/repos/myorg/jx-mytool/releases/tags/v1.0.0":
+			fmt.Fprintf(w, `{"tag_name": "v1.0.0", "assets": [{"name": "jx-mytool-%s-%s", "browser_download_url": "%s/binary"}]}`, runtime.GOOS, runtime.GOARCH, server.URL)
+		case "/binary":
+			fmt.Fprint(w, "#!/bin/sh\necho mytool\n")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	buf := &bytes.Buffer{}
+	_, o := plugin.NewCmdPluginInstall()
+	o.Name = "github.com/myorg/jx-mytool@1.0.0"
+	o.PluginBinDir = dir
+	o.Installer = &plugins.Installer{
+		Lock:       &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+		SkipVerify: true,
+		Mirror:     &config.Mirror{GitHubAPIURL: server.URL + "/api/"},
+		Client:     server.Client(),
+		Token:      "test-token",
+	}
+	o.Sources = &plugins.Sources{Path: filepath.Join(t.TempDir(), plugins.SourcesFileName)}
+	o.Out = buf
+	err := o.Run()
+	require.NoError(t, err)
+	assert.FileExists(t, plugins.PluginBinary(dir, "jx-mytool", "1.0.0"))
+
+	sources, err := plugins.LoadSourcesFile(o.Sources.Path)
+	require.NoError(t, err)
+	src := sources.Find("jx-mytool")
+	require.NotNil(t, src, "should have recorded the source")
+	assert.Equal(t, "github.com/myorg/jx-mytool", src.String())
+}
@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdPruneLong = templates.LongDesc(`
+		Removes old plugin versions from the plugin directory.
+
+		The version of each managed plugin pinned by this version of jx is kept along with the most recent
+		versions of any other plugins.
+`)
+
+	cmdPruneExample = templates.Examples(`
+		# reports which plugin versions would be removed and how much space would be reclaimed
+		jx plugin prune --dry-run
+
+		# removes old plugin versions keeping the 2 most recent versions of unmanaged plugins
+		jx plugin prune --keep 2
+	`)
+)
+
+// PruneOptions the options for pruning plugins
+type PruneOptions struct {
+	PluginBinDir string
+	Keep         int
+	DryRun       bool
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdPluginPrune creates a command object for pruning plugins
+func NewCmdPluginPrune() (*cobra.Command, *PruneOptions) {
+	o := &PruneOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "prune",
+		Short:   "Removes old plugin versions from the plugin directory",
+		Long:    cmdPruneLong,
+		Example: cmdPruneExample,
+		Run: func(_ *cobra.Command, _ []string) {
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().IntVarP(&o.Keep, "keep", "k", plugins.DefaultPruneKeep, "the number of the most recent versions of unmanaged plugins to keep")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "only report the plugin versions which would be removed")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format of the dry run report. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *PruneOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Keep < 1 {
+		return fmt.Errorf("invalid --keep value %d, at least one version must be kept", o.Keep)
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	prunable, err := plugins.FindPrunablePlugins(o.PluginBinDir, o.Keep)
+	if err != nil {
+		return err
+	}
+	if o.DryRun {
+		if o.Output == "json" {
+			return writeJSON(o.Out, prunable)
+		}
+		var size int64
+		for _, p := range prunable {
+			fmt.Fprintf(o.Out, "would remove %s version %s (%s)\n", p.Binary, p.Version, plugins.FormatBytes(p.Size))
+			size += p.Size
+		}
+		fmt.Fprintf(o.Out, "would reclaim %s from %d plugin versions\n", plugins.FormatBytes(size), len(prunable))
+		return nil
+	}
+
+	size, err := plugins.PrunePlugins(prunable)
+	if err != nil {
+		return err
+	}
+	log.Logger().Infof("reclaimed %s from %d plugin versions", termcolor.ColorInfo(plugins.FormatBytes(size)), len(prunable))
+	return nil
+}
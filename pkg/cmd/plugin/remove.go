@@ -0,0 +1,116 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdRemoveLong = templates.LongDesc(`
+		Removes the installed versions of a plugin from the plugin directory.
+
+		Managed plugins are installed again the next time they are used. Removing all the versions of a plugin
+		installed from a repository also forgets the repository it was installed from.
+`)
+
+	cmdRemoveExample = templates.Examples(`
+		# removes all the installed versions of the foo plugin
+		jx plugin remove foo
+
+		# removes a single version of the foo plugin
+		jx plugin remove foo --version 1.2.3
+	`)
+)
+
+// RemoveOptions the options for removing a plugin
+type RemoveOptions struct {
+	PluginBinDir string
+	Name         string
+	Version      string
+	Sources      *plugins.Sources
+}
+
+// NewCmdPluginRemove creates a command object for removing a plugin
+func NewCmdPluginRemove() (*cobra.Command, *RemoveOptions) {
+	o := &RemoveOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "remove NAME",
+		Short:             "Removes the installed versions of a plugin",
+		Aliases:           []string{"rm", "delete"},
+		Long:              cmdRemoveLong,
+		Example:           cmdRemoveExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePluginNames,
+		Run: func(_ *cobra.Command, args []string) {
+			o.Name = args[0]
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Version, "version", "v", "", "the version to remove. If not specified all installed versions are removed")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *RemoveOptions) Run() error {
+	var err error
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	binaryName := plugins.BinaryName(o.Name)
+	versions, err := plugins.InstalledVersions(o.PluginBinDir, binaryName)
+	if err != nil {
+		return err
+	}
+	if o.Version != "" {
+		found := false
+		for _, v := range versions {
+			if v == o.Version {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("version %s of plugin %s is not installed", o.Version, o.Name)
+		}
+		versions = []string{o.Version}
+	}
+	if len(versions) == 0 {
+		return fmt.Errorf("plugin %s is not installed in %s", o.Name, o.PluginBinDir)
+	}
+
+	for _, v := range versions {
+		path := plugins.PluginBinary(o.PluginBinDir, binaryName, v)
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		log.Logger().Infof("removed plugin %s version %s", termcolor.ColorInfo(binaryName), termcolor.ColorInfo(v))
+	}
+	if o.Version != "" {
+		return nil
+	}
+
+	if o.Sources == nil {
+		o.Sources, err = plugins.LoadSources()
+		if err != nil {
+			return err
+		}
+	}
+	if o.Sources.Remove(binaryName) {
+		return o.Sources.Save()
+	}
+	return nil
+}
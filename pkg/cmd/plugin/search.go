@@ -0,0 +1,125 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdSearchLong = templates.LongDesc(`
+		Searches the plugin indexes for plugins whose name or description contains the search term.
+
+		The managed plugins of this version of jx are always searched via the jx index. Additional indexes
+		can be configured in ~/.jx3/config.yaml so that teams can publish their own plugins:
+
+			pluginIndexes:
+			- name: platform
+			  git: https://github.com/myorg/jx-plugin-index.git
+			  path: plugins
+			- name: tools
+			  url: https://example.com/jx/plugins.yaml
+
+		Each index is a YAML list of jenkins.io/v1 Plugin resources. Indexes are cached in the jx cache directory
+		and fetched again once the cache has expired or when --refresh is specified.
+`)
+
+	cmdSearchExample = templates.Examples(`
+		# lists the plugins in all indexes
+		jx plugin search
+
+		# searches for plugins relating to secrets
+		jx plugin search secret
+	`)
+)
+
+// SearchOptions the options for searching the plugin indexes
+type SearchOptions struct {
+	Term    string
+	Refresh bool
+	Output  string
+	Loader  *plugins.IndexLoader
+	Out     io.Writer
+}
+
+// SearchResult a plugin found in a plugin index
+type SearchResult struct {
+	Name        string `json:"name"`
+	Binary      string `json:"binary"`
+	Version     string `json:"version"`
+	Index       string `json:"index"`
+	Description string `json:"description,omitempty"`
+}
+
+// NewCmdPluginSearch creates a command object for searching the plugin indexes
+func NewCmdPluginSearch() (*cobra.Command, *SearchOptions) {
+	o := &SearchOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "search [term]",
+		Short:   "Searches the plugin indexes for plugins",
+		Long:    cmdSearchLong,
+		Example: cmdSearchExample,
+		Args:    cobra.MaximumNArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if len(args) > 0 {
+				o.Term = args[0]
+			}
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().BoolVarP(&o.Refresh, "refresh", "", false, "fetches the plugin indexes even if the cached copies have not expired")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *SearchOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.Loader == nil {
+		o.Loader, err = plugins.NewIndexLoader()
+		if err != nil {
+			return err
+		}
+	}
+	o.Loader.Refresh = o.Refresh
+
+	results := []*SearchResult{}
+	for _, p := range plugins.SearchIndex(o.Loader.Load(), o.Term) {
+		spec := &p.Plugin.Spec
+		results = append(results, &SearchResult{
+			Name:        strings.TrimPrefix(spec.Name, "jx-"),
+			Binary:      spec.Name,
+			Version:     spec.Version,
+			Index:       p.Index,
+			Description: spec.Description,
+		})
+	}
+	if o.Output == "json" {
+		return writeJSON(o.Out, results)
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("no plugins found matching %s", o.Term)
+	}
+
+	w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0) //nolint:mnd
+	fmt.Fprintln(w, "NAME\tVERSION\tINDEX\tDESCRIPTION")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", r.Name, r.Version, r.Index, r.Description)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdWhichLong = templates.LongDesc(`
+		Displays the path of the binary used when invoking a plugin
+`)
+
+	cmdWhichExample = templates.Examples(`
+		# displays the binary used for 'jx gitops'
+		jx plugin which gitops
+	`)
+)
+
+// WhichOptions the options for finding the binary of a plugin
+type WhichOptions struct {
+	PluginBinDir string
+	Name         string
+	Output       string
+	Out          io.Writer
+}
+
+// NewCmdPluginWhich creates a command object for finding the binary of a plugin
+func NewCmdPluginWhich() (*cobra.Command, *WhichOptions) {
+	o := &WhichOptions{}
+
+	cmd := &cobra.Command{
+		Use:               "which NAME",
+		Short:             "Displays the path of the binary used when invoking a plugin",
+		Long:              cmdWhichLong,
+		Example:           cmdWhichExample,
+		Args:              cobra.ExactArgs(1),
+		ValidArgsFunction: completePluginNames,
+		Run: func(_ *cobra.Command, args []string) {
+			o.Name = args[0]
+			err := o.Run()
+			helper.CheckErr(err)
+		},
+	}
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format. Supported values: json")
+	return cmd, o
+}
+
+// Run implements the command
+func (o *WhichOptions) Run() error {
+	err := validateOutput(o.Output)
+	if err != nil {
+		return err
+	}
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	if o.PluginBinDir == "" {
+		o.PluginBinDir, err = homedir.DefaultPluginBinDir()
+		if err != nil {
+			return fmt.Errorf("failed to find plugin bin directory: %w", err)
+		}
+	}
+
+	p, err := plugins.GetPluginInfo(o.PluginBinDir, o.Name)
+	if err != nil {
+		return err
+	}
+	if o.Output == "json" {
+		return writeJSON(o.Out, p)
+	}
+	if p.Path == "" {
+		if p.Managed {
+			return fmt.Errorf("version %s of plugin %s is not installed yet", p.Version, p.Binary)
+		}
+		return fmt.Errorf("plugin %s is not installed", p.Binary)
+	}
+	if p.Shadowed {
+		log.Logger().Warnf("%s shadows the versions installed in %s", p.Path, o.PluginBinDir)
+	} else if p.Managed && p.PathBinary != "" {
+		log.Logger().Warnf("%s on the PATH is ignored as %s is a managed plugin", p.PathBinary, p.Binary)
+	}
+	fmt.Fprintln(o.Out, p.Path)
+	return nil
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
@@ -13,15 +14,38 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 	"github.com/jenkins-x/jx/pkg/cmd/dashboard"
+	cmdhistory "github.com/jenkins-x/jx/pkg/cmd/history"
 	"github.com/jenkins-x/jx/pkg/cmd/namespace"
+	"github.com/jenkins-x/jx/pkg/cmd/plugin"
 	"github.com/jenkins-x/jx/pkg/cmd/upgrade"
 	"github.com/jenkins-x/jx/pkg/cmd/version"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/history"
 	"github.com/jenkins-x/jx/pkg/plugins"
 	"github.com/spf13/cobra"
 )
 
+// offlineFlag the global flag which stops plugins being downloaded
+const offlineFlag = "offline"
+
 // Main creates the new command
 func Main(args []string) *cobra.Command {
+	// lets use the plugin versions of the version stream if one is configured or we are inside a cluster git repository
+	err := plugins.UseVersionStream("")
+	if err != nil {
+		log.Logger().Warnf("using the default plugin versions as failed to resolve them from the version stream: %s", err.Error())
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Logger().Warnf("ignoring the jx configuration such as aliases, hooks and history settings as failed to load it: %s", err.Error())
+		cfg = &config.Config{}
+	}
+	recorder := history.NewRecorder(cfg)
+	if cfg.Offline.Enabled {
+		os.Setenv(plugins.OfflineEnvVar, "true")
+	}
+
 	cmd := &cobra.Command{
 		Use:   "jx",
 		Short: "JayeX 3.x command line",
@@ -30,33 +54,50 @@ func Main(args []string) *cobra.Command {
 		// respectively.
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 
+			if offline, _ := cmd.Flags().GetBool(offlineFlag); offline {
+				os.Setenv(plugins.OfflineEnvVar, "true")
+			}
 			if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
 				// This is the __complete or __completeNoDesc command which
 				// indicates shell completion has been requested.
 				plugins.SetupPluginCompletion(cmd, args)
+				return nil
 			}
+			recordHistory(cmd, recorder)
 			return nil
 		},
+		PersistentPostRun: func(_ *cobra.Command, _ []string) {
+			recorder.Finish(0)
+		},
 	}
+	cmd.PersistentFlags().Bool(offlineFlag, false, "never download plugins, using compatible installed versions instead. Can also be enabled via $"+plugins.OfflineEnvVar)
 
 	getPluginCommandGroups := func() templates.PluginCommandGroups {
 		verifier := &extensions.CommandOverrideVerifier{
 			Root:        cmd,
 			SeenPlugins: make(map[string]string),
 		}
-		pluginCommandGroups, err := templates.GetPluginCommandGroups(verifier, plugins.Plugins)
+		localPlugins := plugins.Plugins
+		pluginBinDir, err := homedir.DefaultPluginBinDir()
+		if err == nil {
+			// lets use the descriptions from the cached metadata of the installed plugins without invoking them
+			localPlugins = plugins.DescribePlugins(pluginBinDir, localPlugins)
+		}
+		pluginCommandGroups, err := templates.GetPluginCommandGroups(verifier, localPlugins)
 		if err != nil {
 			log.Logger().Errorf("%v", err)
 		}
 		return pluginCommandGroups
 	}
 	doCmd := func(cmd *cobra.Command, args []string) {
-		handleCommand(cmd, args)
+		handleCommand(cmd, args, cfg, recorder)
 	}
 
 	generalCommands := []*cobra.Command{
 		cobras.SplitCommand(dashboard.NewCmdDashboard()),
+		cobras.SplitCommand(cmdhistory.NewCmdHistory()),
 		cobras.SplitCommand(namespace.NewCmdNamespace()),
+		cobras.SplitCommand(plugin.NewCmdPlugin()),
 		cobras.SplitCommand(upgrade.NewCmdUpgrade()),
 		cobras.SplitCommand(version.NewCmdVersion()),
 	}
@@ -146,6 +187,8 @@ func Main(args []string) *cobra.Command {
 
 	cmd.AddCommand(generalCommands...)
 
+	aliasCommands := addUserAliases(cmd, doCmd, cfg.Aliases)
+
 	var groups templates.CommandGroups
 	command := templates.CommandGroup{
 
@@ -153,22 +196,50 @@ func Main(args []string) *cobra.Command {
 		Commands: generalCommands,
 	}
 	groups = append(groups, command)
+	if len(aliasCommands) > 0 {
+		groups = append(groups, templates.CommandGroup{
+			Message:  "Aliases:",
+			Commands: aliasCommands,
+		})
+	}
 
 	groups.Add(cmd)
 	filters := []string{"options"}
 
 	templates.ActsAsRootCommand(cmd, filters, getPluginCommandGroups, groups...)
-	handleCommand(cmd, args)
+	handleCommand(cmd, args, cfg, recorder)
 	return cmd
 }
 
-func handleCommand(cmd *cobra.Command, args []string) {
+// recordHistory starts recording the built in command to the history making sure failures record their exit code
+func recordHistory(cmd *cobra.Command, recorder *history.Recorder) {
+	if recorder == nil {
+		return
+	}
+	switch cmd.Name() {
+	case "help", "completion", "history":
+		return
+	}
+	recorder.Start(os.Args[1:])
+	helper.BehaviorOnFatal(func(msg string, code int) {
+		if msg != "" {
+			if !strings.HasSuffix(msg, "\n") {
+				msg += "\n"
+			}
+			fmt.Fprint(os.Stderr, msg)
+		}
+		recorder.Finish(code)
+		os.Exit(code)
+	})
+}
+
+func handleCommand(cmd *cobra.Command, args []string, cfg *config.Config, recorder *history.Recorder) {
 
 	if len(args) == 0 {
 		args = os.Args
 	}
 	if len(args) > 1 {
-		cmdPathPieces := args[1:]
+		cmdPathPieces := applyOfflineFlag(args[1:])
 		pluginDir, err := homedir.DefaultPluginBinDir()
 		if err != nil {
 			log.Logger().Errorf("%v", err)
@@ -188,17 +259,80 @@ func handleCommand(cmd *cobra.Command, args []string) {
 			switch cmdName {
 			case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd, "completion":
 				// Don't search for a plugin
+			case plugins.RefreshCompletionArg:
+				// refreshing cached plugin completions in the background
+				err := plugins.RefreshCompletion(cmdPathPieces[1:])
+				if err != nil {
+					os.Exit(1)
+				}
+				os.Exit(0)
 			default:
-				if err := handleEndpointExtensions(cmdPathPieces, pluginDir); err != nil {
-					log.Logger().Errorf("%v", err)
-					// Adding plugins to cmd to get correct suggestions for misspelling
-					plugins.RegisterPluginCommands(cmd, true)
+				found, exitCode, err := handleEndpointExtensions(cmdPathPieces, pluginDir, cfg, recorder, nil)
+				if err != nil {
+					if plugins.IsUnknownPluginError(err) {
+						reportUnknownCommand(os.Stderr, cmd, cmdPathPieces)
+					} else {
+						log.Logger().Errorf("%v", err)
+					}
+					os.Exit(1)
+				} else if found {
+					os.Exit(exitCode)
 				}
 			}
 		}
 	}
 }
 
+// reportUnknownCommand reports that the command was not found suggesting similar built in commands, aliases,
+// plugins, plugin subcommands and the plugins in the cached plugin indexes
+func reportUnknownCommand(out io.Writer, rootCmd *cobra.Command, args []string) {
+	plugins.RegisterCachedPluginCommands(rootCmd)
+	plugins.RegisterIndexPluginCommands(rootCmd)
+	var words []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		words = append(words, arg)
+	}
+	fmt.Fprintf(out, "Error: unknown command %q for %q\n", strings.Join(words, " "), rootCmd.Name())
+	suggestions := plugins.SuggestCommands(rootCmd, words)
+	if len(suggestions) > 0 {
+		fmt.Fprintf(out, "\nDid you mean this?\n")
+		for _, s := range suggestions {
+			fmt.Fprintf(out, "\t%s\n", termcolor.ColorInfo(s))
+		}
+	}
+	fmt.Fprintf(out, "\nRun '%s --help' for usage or 'jx plugin search' to find plugins.\n", rootCmd.Name())
+}
+
+// recordingExecutor records the command in the history just before the plugin replaces the jx process
+type recordingExecutor struct {
+	plugins.Executor
+	recorder *history.Recorder
+}
+
+// Execute records the command and then executes the plugin
+func (e *recordingExecutor) Execute(binary string, args, environment []string) (int, error) {
+	e.recorder.Exec()
+	return e.Executor.Execute(binary, args, environment)
+}
+
+// applyOfflineFlag enables offline mode if the --offline flag is specified before the command name, removing it
+// from the arguments so that it is not passed to plugins
+func applyOfflineFlag(args []string) []string {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if arg == "--"+offlineFlag {
+			os.Setenv(plugins.OfflineEnvVar, "true")
+			return append(append([]string{}, args[:i]...), args[i+1:]...)
+		}
+	}
+	return args
+}
+
 func aliasCommand(rootCmd *cobra.Command, fn func(cmd *cobra.Command, args []string), name string, args []string, aliases ...string) *cobra.Command {
 	realArgs := append([]string{"jx"}, args...)
 	cmd := &cobra.Command{
@@ -226,7 +360,10 @@ func runHelp(cmd *cobra.Command, _ []string) {
 	cmd.Help() //nolint:errcheck
 }
 
-func handleEndpointExtensions(cmdArgs []string, pluginBinDir string) error {
+// handleEndpointExtensions invokes the plugin for the command arguments returning its exit code if one was found.
+//
+// If the executor is nil the plugin replaces the current process unless it has to run as a child process for the hooks or history
+func handleEndpointExtensions(cmdArgs []string, pluginBinDir string, cfg *config.Config, recorder *history.Recorder, executor plugins.Executor) (bool, int, error) {
 	var remainingArgs []string // all "non-flag" arguments
 
 	for idx := range cmdArgs {
@@ -237,36 +374,71 @@ func handleEndpointExtensions(cmdArgs []string, pluginBinDir string) error {
 	}
 
 	foundBinaryPath := ""
+	managed := false
+
+	// lets use any plugin versions pinned by the current repository
+	projectPlugins, err := plugins.FindProjectPlugins("")
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to load the plugin versions pinned by the current repository: %w", err)
+	}
+
+	// pinned plugin versions can be found in any directory of the search path such as a shared read only directory
+	searchPath := plugins.NewSearchPath(pluginBinDir)
+	searchPath.Compatible = cfg.Offline.Compatible
 
-	// attempt to find binary, starting at longest possible name with given cmdArgs
-	var err error
-	for len(remainingArgs) > 0 {
-		commandName := fmt.Sprintf("jx-%s", strings.Join(remainingArgs, "-"))
+	// only known plugins are installed so that unknown commands never use the network
+	resolver := plugins.NewResolver(searchPath)
+
+	// attempt to find binary, starting at longest possible name with given cmdArgs. The local plugins are tried for
+	// every name first so that the plugin indexes are only loaded, and the names which are not plugins remembered,
+	// when no local plugin matches such as for the positional arguments of 'jx promote myapp 1.2.3'
+	found := 0
+	for n := len(remainingArgs); n > 0 && found == 0; n-- {
+		commandName := fmt.Sprintf("jx-%s", strings.Join(remainingArgs[:n], "-"))
 
 		// lets try the correct plugin versions first
 		path := ""
-		if plugins.PluginMap[commandName] != nil {
+		if p := projectPlugins.Plugin(commandName); p != nil {
+			log.Logger().Debugf("using plugin %s version %s pinned in %s", commandName, p.Spec.Version, projectPlugins.Path)
+			path, err = searchPath.EnsurePluginInstalled(*p)
+			if err != nil {
+				return false, 0, fmt.Errorf("failed to install binary plugin %s version %s pinned in %s to %s: %w", commandName, p.Spec.Version, projectPlugins.Path, pluginBinDir, err)
+			}
+			managed = plugins.IsJenkinsXPlugin(p)
+		} else if plugins.PluginMap[commandName] != nil {
 			p := *plugins.PluginMap[commandName]
-			path, err = extensions.EnsurePluginInstalled(p, pluginBinDir)
+			path, err = searchPath.EnsurePluginInstalled(p)
 			if err != nil {
-				return fmt.Errorf("failed to install binary plugin %s version %s to %s: %w", commandName, p.Spec.Version, pluginBinDir, err)
+				return false, 0, fmt.Errorf("failed to install binary plugin %s version %s to %s: %w", commandName, p.Spec.Version, pluginBinDir, err)
 			}
+			managed = true
 		}
 
-		// lets see if there's a local build of the plugin on the PATH for developers...
+		// lets see if there's a local build of the plugin in the search path for developers...
 		if path == "" {
-			path, err = plugins.Lookup(commandName, pluginBinDir)
+			path, err = resolver.ResolveLocal(commandName)
 		}
 		if path != "" {
 			foundBinaryPath = path
-			break
+			found = n
+		}
+	}
+	for n := len(remainingArgs); n > 0 && found == 0; n-- {
+		path, indexErr := resolver.InstallFromIndex(fmt.Sprintf("jx-%s", strings.Join(remainingArgs[:n], "-")))
+		if path != "" {
+			foundBinaryPath = path
+			found = n
+		}
+		// lets report a failure to install a local plugin rather than it not being in the plugin indexes
+		if err == nil || plugins.IsUnknownPluginError(err) {
+			err = indexErr
 		}
-		remainingArgs = remainingArgs[:len(remainingArgs)-1]
 	}
 
 	if foundBinaryPath == "" {
-		return err
+		return false, 0, err
 	}
+	remainingArgs = remainingArgs[:found]
 
 	nextArgs := cmdArgs[len(remainingArgs):]
 	log.Logger().Debugf("using the plugin command: %s", termcolor.ColorInfo(foundBinaryPath+" "+strings.Join(nextArgs, " ")))
@@ -275,8 +447,34 @@ func handleEndpointExtensions(cmdArgs []string, pluginBinDir string) error {
 	pluginCommandName := os.Args[0] + " " + strings.Join(remainingArgs, " ")
 	os.Setenv("BINARY_NAME", pluginCommandName)
 	os.Setenv("TOP_LEVEL_COMMAND", pluginCommandName)
-	// invoke cmd binary relaying the current environment and args given
-	// remainingArgs will always have at least one element.
-	// execute will make remainingArgs[0] the "binary name".
-	return plugins.Execute(foundBinaryPath, nextArgs, os.Environ())
+
+	// lets only pass the environment variables the plugin is allowed to see
+	pluginName := "jx-" + strings.Join(remainingArgs, "-")
+	pluginEnv := plugins.FilterEnv(&cfg.Env, pluginName, managed, os.Environ())
+
+	hooks, err := plugins.LoadHooks(cfg)
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to load plugin hooks: %w", err)
+	}
+	if executor == nil {
+		// post hooks and timing the plugin need it to run as a child process so that they can run after it completes
+		child := hooks.HasPost() || recorder.Timed()
+		executor = plugins.NewExecutor(child)
+		if !child {
+			executor = &recordingExecutor{Executor: executor, recorder: recorder}
+		}
+	}
+	hooks.Executor = executor
+
+	invocation := plugins.NewInvocation(pluginName, foundBinaryPath, nextArgs)
+	invocation.Env = pluginEnv
+	recorder.Start(os.Args[1:])
+	recorder.SetPlugin(pluginName, foundBinaryPath, invocation.Version)
+	exitCode, err := hooks.Run(invocation, os.Environ())
+	if err != nil {
+		recorder.Finish(1)
+		return true, 0, err
+	}
+	recorder.Finish(exitCode)
+	return true, exitCode, nil
 }
@@ -1,10 +1,19 @@
 package cmd_test
 
 import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 
 	"github.com/jenkins-x/jx/pkg/cmd"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(t *testing.T) {
@@ -12,3 +21,110 @@ func TestMain(t *testing.T) {
 	err := rootCmd.Execute()
 	assert.NoError(t, err)
 }
+
+func TestUserAliases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte(`aliases:
+- name: envs
+  parent: get
+  args: [gitops, get, environments]
+  aliases: [env]
+- name: lint
+  parent: team
+  args: [gitops, lint]
+  short: lints the cluster repository
+- name: upgrade
+  args: [gitops, upgrade]
+- name: gitops
+  args: [admin, log]
+- name: previews
+  parent: get
+  args: [preview, list]
+- name: sync
+  parent: gitops
+  args: [gitops, upgrade]
+- name: deploy
+  parent: mytool
+  args: [version]
+`), 0o600)
+	require.NoError(t, err)
+	t.Setenv(config.FileEnvVar, path)
+
+	// an installed plugin which should not be shadowed by an alias parent
+	home := t.TempDir()
+	t.Setenv("JX3_HOME", home)
+	pluginBinDir := filepath.Join(home, "plugins", "bin")
+	require.NoError(t, os.MkdirAll(pluginBinDir, 0o755))
+	err = os.WriteFile(filepath.Join(pluginBinDir, "jx-mytool-1.0.0"), []byte("#!/bin/sh\n"), 0o700) //nolint:gosec
+	require.NoError(t, err)
+
+	rootCmd := cmd.Main([]string{""})
+
+	envs, _, err := rootCmd.Find([]string{"get", "env"})
+	require.NoError(t, err)
+	assert.Equal(t, "envs", envs.Name())
+	assert.Equal(t, "alias for: jx gitops get environments", envs.Short)
+
+	lint, _, err := rootCmd.Find([]string{"team", "lint"})
+	require.NoError(t, err)
+	assert.Equal(t, "lint", lint.Name())
+	assert.Equal(t, "lints the cluster repository", lint.Short)
+
+	upgradeCmd, _, err := rootCmd.Find([]string{"upgrade"})
+	require.NoError(t, err)
+	assert.NotContains(t, upgradeCmd.Short, "alias for", "should not replace the built in command")
+
+	_, _, err = rootCmd.Find([]string{"gitops"})
+	assert.Error(t, err, "should not shadow the gitops plugin")
+
+	_, _, err = rootCmd.Find([]string{"mytool"})
+	assert.Error(t, err, "should not shadow the installed mytool plugin")
+
+	previews, _, err := rootCmd.Find([]string{"get", "previews"})
+	require.NoError(t, err)
+	assert.Equal(t, "alias for: jx preview get", previews.Short, "should not replace the built in alias")
+}
+
+func TestCompletionNeverUsesTheNetwork(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	home := t.TempDir()
+	path := filepath.Join(home, "config.yaml")
+	err := os.WriteFile(path, []byte(`mirror:
+  githubURL: `+server.URL+`/
+  githubAPIURL: `+server.URL+`/api/
+pluginIndexes:
+- name: platform
+  url: `+server.URL+`/plugins.yaml
+aliases:
+- name: envs
+  parent: get
+  args: [gitops, get, environments]
+`), 0o600)
+	require.NoError(t, err)
+	t.Setenv("JX3_HOME", home)
+	t.Setenv(config.FileEnvVar, path)
+	t.Setenv(plugins.PluginPathEnvVar, t.TempDir())
+
+	for _, args := range [][]string{
+		{"__complete", ""},
+		{"__complete", "gitops", ""},
+		{"__complete", "gitops", "get", ""},
+		{"__complete", "doesnotexist", ""},
+		{"__complete", "get", "envs", ""},
+		{"__completeNoDesc", "admin", "--"},
+	} {
+		rootCmd := cmd.Main(append([]string{"jx"}, args...))
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		err = rootCmd.Execute()
+		assert.NoError(t, err, "for %v", args)
+	}
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests), "should not use the network during completion")
+}
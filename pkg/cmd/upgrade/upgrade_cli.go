@@ -1,6 +1,7 @@
 package upgrade
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -19,7 +20,11 @@ import (
 	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
 
 	"github.com/jenkins-x/jx/pkg/cmd/version"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/jenkins-x/jx/pkg/signature"
 
+	"github.com/inconshreveable/go-update"
 	jxcore "github.com/jenkins-x/jx-api/v4/pkg/apis/core/v4beta1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/rhysd/go-github-selfupdate/selfupdate"
@@ -59,9 +64,12 @@ const (
 type CLIOptions struct {
 	CommandRunner       cmdrunner.CommandRunner
 	GitClient           gitclient.Interface
+	Verifier            *signature.Verifier
+	Mirror              *config.Mirror
 	Version             string
 	VersionStreamGitURL string
 	FromEnvironment     bool
+	InsecureSkipVerify  bool
 }
 
 // NewCmdUpgradeCLI creates new upgrade cmd
@@ -81,6 +89,7 @@ func NewCmdUpgradeCLI() (*cobra.Command, *CLIOptions) {
 	cmd.Flags().StringVarP(&o.Version, "version", "v", "", "The specific version to upgrade to (requires --brew=false on macOS)")
 	cmd.Flags().StringVarP(&o.VersionStreamGitURL, "version-stream-git-url", "", "", "The version stream git URL to lookup the jx cli version to upgrade to")
 	cmd.Flags().BoolVarP(&o.FromEnvironment, "from-environment", "e", false, "Use the clusters dev environment to obtain the version stream URL to find correct version to upgrade the jx cli, this overrides version-stream-git-url")
+	cmd.Flags().BoolVarP(&o.InsecureSkipVerify, "insecure-skip-verify", "", false, "disables the verification of the signature of the downloaded jx release")
 	return cmd, o
 }
 
@@ -225,7 +234,7 @@ func (*CLIOptions) ShouldUpdate(newVersion semver.Version) (bool, error) {
 }
 
 // InstallJx installs jx cli
-func (*CLIOptions) InstallJx(upgrade bool, version string) error {
+func (o *CLIOptions) InstallJx(upgrade bool, version string) error {
 	log.Logger().Debugf("installing jx %s", version)
 	binary := "jx"
 	if !upgrade {
@@ -241,12 +250,32 @@ func (*CLIOptions) InstallJx(upgrade bool, version string) error {
 	}
 	log.Logger().Infof("downloading version %s...", version)
 	clientURL := fmt.Sprintf("%s%s/"+binary+"-%s-%s.%s", BinaryDownloadBaseURL, version, runtime.GOOS, runtime.GOARCH, extension)
+	if o.Mirror == nil {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load jx configuration: %w", err)
+		}
+		o.Mirror = &cfg.Mirror
+	}
+	clientURL = o.Mirror.DownloadURL(clientURL)
 	exe, err := os.Executable()
 	if err != nil {
 		return fmt.Errorf("failed to get the jx executable which is running this command: %w", err)
 	}
 
-	err = selfupdate.UpdateTo(clientURL, exe)
+	data, err := plugins.Download(nil, clientURL)
+	if err != nil {
+		return err
+	}
+	err = o.verifySignature(clientURL, data)
+	if err != nil {
+		return err
+	}
+	asset, err := selfupdate.UncompressCommand(bytes.NewReader(data), clientURL, filepath.Base(exe))
+	if err != nil {
+		return fmt.Errorf("failed to extract jx from %s: %w", clientURL, err)
+	}
+	err = update.Apply(asset, update.Options{TargetPath: exe})
 	if err != nil {
 		return fmt.Errorf("failed to upgrade jx cli to version %s: %w", version, err)
 	}
@@ -254,6 +283,30 @@ func (*CLIOptions) InstallJx(upgrade bool, version string) error {
 	return nil
 }
 
+// verifySignature verifies the detached signature of the jx release archive downloaded from the given URL
+func (o *CLIOptions) verifySignature(clientURL string, data []byte) error {
+	if o.InsecureSkipVerify || !signature.VerifyEnabled() {
+		log.Logger().Debugf("skipping signature verification of %s", clientURL)
+		return nil
+	}
+	if o.Verifier == nil {
+		var err error
+		o.Verifier, err = signature.NewVerifier()
+		if err != nil {
+			return fmt.Errorf("failed to load trusted public keys: %w", err)
+		}
+	}
+	sig, err := plugins.Download(nil, clientURL+signature.Suffix)
+	if err != nil {
+		return fmt.Errorf("failed to download signature of %s: %w", clientURL, err)
+	}
+	err = o.Verifier.Verify(data, sig)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature of %s: %w", clientURL, err)
+	}
+	return nil
+}
+
 func (o *CLIOptions) getJXVersion(gitURL string) (string, error) {
 	if o.GitClient == nil {
 		o.GitClient = cli.NewCLIClient("", cmdrunner.QuietCommandRunner)
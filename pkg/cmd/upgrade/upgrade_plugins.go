@@ -1,14 +1,18 @@
 package upgrade
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"regexp"
+	"sort"
+	"text/tabwriter"
 
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/helper"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/cobras/templates"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
 	"github.com/jenkins-x/jx-logging/v3/pkg/log"
@@ -25,6 +29,18 @@ var (
 	cmdPluginsExample = templates.Examples(`
 		# upgrades your plugin binaries
 		jx upgrade plugins
+
+		# upgrades your plugin binaries and removes the old versions
+		jx upgrade plugins --prune
+
+		# reports which plugins would be installed or upgraded without changing anything
+		jx upgrade plugins --dry-run
+
+		# fails if any plugins are out of date such as when building images in CI
+		jx upgrade plugins --check -o json
+
+		# upgrades the plugins to the versions in a version stream
+		jx upgrade plugins --version-stream-dir ./versionStream
 	`)
 
 	bootPlugins = map[string]bool{
@@ -38,10 +54,20 @@ var (
 
 // PluginOptions the options for upgrading plugins
 type PluginOptions struct {
-	CommandRunner cmdrunner.CommandRunner
-	OnlyMandatory bool
-	Boot          bool
-	Path          string
+	CommandRunner      cmdrunner.CommandRunner
+	OnlyMandatory      bool
+	Boot               bool
+	InsecureSkipVerify bool
+	Prune              bool
+	PruneKeep          int
+	Concurrency        int
+	DryRun             bool
+	Check              bool
+	Output             string
+	VersionStreamDir   string
+	Path               string
+	Progress           plugins.Progress
+	Out                io.Writer
 }
 
 // NewCmdUpgradePlugins creates a command object for upgrading plugins
@@ -61,86 +87,225 @@ func NewCmdUpgradePlugins() (*cobra.Command, *PluginOptions) {
 	cmd.Flags().BoolVarP(&o.OnlyMandatory, "mandatory", "m", false, "if set lets ignore optional plugins")
 	cmd.Flags().BoolVarP(&o.Boot, "boot", "", false, "only install plugins required for boot")
 	cmd.Flags().StringVarP(&o.Path, "path", "", "/usr/bin", "creates a symlink to the binary plugins in this bin path dir")
+	cmd.Flags().BoolVarP(&o.Prune, "prune", "", false, "removes old plugin versions from the plugin directory after upgrading")
+	cmd.Flags().IntVarP(&o.PruneKeep, "prune-keep", "", plugins.DefaultPruneKeep, "the number of the most recent versions of unmanaged plugins to keep when pruning")
+	cmd.Flags().BoolVarP(&o.DryRun, "dry-run", "", false, "reports which plugins would be installed or upgraded without changing anything")
+	cmd.Flags().BoolVarP(&o.Check, "check", "", false, "reports which plugins are out of date without changing anything and fails if any are")
+	cmd.Flags().StringVarP(&o.Output, "output", "o", "", "the output format of the dry run or check report. Supported values: json")
+	cmd.Flags().StringVarP(&o.VersionStreamDir, "version-stream-dir", "", "", "the version stream dir used to resolve the plugin versions. Defaults to $"+plugins.VersionStreamDirEnvVar+" or the versionStream dir in the current directory if it has a Kptfile whose versions must then already be in the plugin lock")
+	cmd.Flags().IntVarP(&o.Concurrency, "concurrency", "", plugins.DefaultInstallConcurrency, "the maximum number of plugins to download and install concurrently")
+	cmd.Flags().BoolVarP(&o.InsecureSkipVerify, "insecure-skip-verify", "", false, "disables the verification of the signatures of the downloaded plugins")
 
 	return cmd, o
 }
 
 // Run implements the command
 func (o *PluginOptions) Run() error {
+	if o.Output != "" && o.Output != "json" {
+		return fmt.Errorf("unsupported output format %s, supported values: json", o.Output)
+	}
 	pluginBinDir, err := homedir.DefaultPluginBinDir()
 	if err != nil {
 		return fmt.Errorf("failed to find plugin bin directory: %w", err)
 	}
+	if o.VersionStreamDir != "" {
+		err = plugins.UseVersionStream(o.VersionStreamDir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve plugin versions: %w", err)
+		}
+	}
 
 	if o.CommandRunner == nil {
 		o.CommandRunner = cmdrunner.DefaultCommandRunner
 	}
+	installer, err := plugins.NewInstaller()
+	if err != nil {
+		return fmt.Errorf("failed to create plugin installer: %w", err)
+	}
+	if o.InsecureSkipVerify {
+		installer.SkipVerify = true
+	}
+	if o.DryRun || o.Check {
+		return o.reportDrift(installer, pluginBinDir)
+	}
+	if o.Progress == nil {
+		o.Progress = plugins.NewProgress(os.Stderr)
+	}
+	installer.Progress = o.Progress
+
+	var tasks []plugins.InstallTask
+	for _, p := range o.managedPlugins() {
+		tasks = append(tasks, plugins.InstallTask{
+			Name: p.Spec.Name,
+			Install: func() (string, error) {
+				return installer.EnsurePluginInstalled(p, pluginBinDir)
+			},
+		})
+	}
+	if o.includeExtras() {
+		// Upgrade the rest
+		installed, err := plugins.InstalledPlugins(pluginBinDir)
+		if err != nil {
+			return err
+		}
+		for _, plugin := range sortedNames(installed) {
+			if plugins.PluginMap[plugin] != nil {
+				continue
+			}
+			tasks = append(tasks, plugins.InstallTask{
+				Name: plugin,
+				Install: func() (string, error) {
+					return installer.InstallStandardPlugin(pluginBinDir, plugin)
+				},
+			})
+		}
+	}
+
+	log.Logger().Infof("checking %d binary jx plugins are installed", len(tasks))
+	results, installErr := plugins.InstallAll(tasks, o.Concurrency)
+
+	// lets upgrade the plugins of the plugins which installed successfully
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			continue
+		}
+		err = o.upgradePluginPlugins(r.Name, r.Path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if installErr != nil {
+		errs = append([]error{installErr}, errs...)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if o.Prune {
+		return o.prunePlugins(pluginBinDir)
+	}
+	return nil
+}
+
+// managedPlugins returns the managed plugins to upgrade
+func (o *PluginOptions) managedPlugins() []jenkinsv1.Plugin {
+	var answer []jenkinsv1.Plugin
 	for k := range plugins.Plugins {
 		p := plugins.Plugins[k]
 		if o.Boot && !bootPlugins[p.Name] {
 			continue
 		}
-		log.Logger().Infof("checking binary jx plugin %s version %s is installed", termcolor.ColorInfo(p.Name), termcolor.ColorInfo(p.Spec.Version))
-		fileName, err := extensions.EnsurePluginInstalled(p, pluginBinDir)
+		answer = append(answer, p)
+	}
+	return answer
+}
+
+// includeExtras returns true if the plugins which are not managed should be upgraded
+func (o *PluginOptions) includeExtras() bool {
+	return !(o.OnlyMandatory || o.Boot)
+}
+
+// reportDrift reports which plugins would be installed or upgraded failing if checking and any are out of date
+func (o *PluginOptions) reportDrift(installer *plugins.Installer, pluginBinDir string) error {
+	if o.Out == nil {
+		o.Out = os.Stdout
+	}
+	drifts, err := installer.Drift(pluginBinDir, o.managedPlugins(), o.includeExtras())
+	if err != nil {
+		return fmt.Errorf("failed to compare the installed plugins: %w", err)
+	}
+
+	outdated := 0
+	for _, d := range drifts {
+		if d.IsOutdated() {
+			outdated++
+		}
+	}
+	if o.Output == "json" {
+		data, err := json.MarshalIndent(drifts, "", "  ")
 		if err != nil {
-			return fmt.Errorf("failed to ensure plugin is installed %s: %w", p.Name, err)
+			return fmt.Errorf("failed to marshal to JSON: %w", err)
 		}
-
-		if o.Boot {
-			if p.Name == "gitops" {
-				c := &cmdrunner.Command{
-					Name: fileName,
-					Args: []string{"plugin", "upgrade", "--path", o.Path},
-				}
-				_, err = o.CommandRunner(c)
-				if err != nil {
-					return fmt.Errorf("failed to upgrade gitops plugin %s: %w", p.Name, err)
-				}
+		_, err = fmt.Fprintln(o.Out, string(data))
+		if err != nil {
+			return err
+		}
+	} else {
+		w := tabwriter.NewWriter(o.Out, 0, 0, 2, ' ', 0) //nolint:mnd
+		fmt.Fprintln(w, "PLUGIN\tINSTALLED\tTARGET\tACTION")
+		for _, d := range drifts {
+			action := d.Action
+			if d.Error != "" {
+				action += ": " + d.Error
 			}
-			continue
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Name, d.Installed, d.Target, action)
 		}
+		err = w.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.Check && outdated > 0 {
+		return fmt.Errorf("%d of %d plugins are out of date", outdated, len(drifts))
+	}
+	return nil
+}
 
-		// TODO we could use metadata on the plugin for this?
-		if p.Name == "secret" {
+// upgradePluginPlugins upgrades the plugins used by the given plugin binary
+func (o *PluginOptions) upgradePluginPlugins(name, fileName string) error {
+	if o.Boot {
+		if name == "jx-gitops" {
 			c := &cmdrunner.Command{
 				Name: fileName,
-				Args: []string{"plugins", "upgrade"},
+				Args: []string{"plugin", "upgrade", "--path", o.Path},
 			}
-			_, err = o.CommandRunner(c)
+			_, err := o.CommandRunner(c)
 			if err != nil {
-				return fmt.Errorf("failed to upgrade plugin %s: %w", p.Name, err)
+				return fmt.Errorf("failed to upgrade gitops plugin %s: %w", name, err)
 			}
 		}
+		return nil
 	}
-	if !(o.OnlyMandatory || o.Boot) {
-		// Upgrade the rest
-		file, err := os.Open(pluginBinDir)
-		if err != nil {
-			return fmt.Errorf("failed to read plugin dir %s: %w", pluginBinDir, err)
+
+	// TODO we could use metadata on the plugin for this?
+	if name == "jx-secret" {
+		c := &cmdrunner.Command{
+			Name: fileName,
+			Args: []string{"plugins", "upgrade"},
 		}
-		defer file.Close()
-		files, err := file.Readdirnames(0)
+		_, err := o.CommandRunner(c)
 		if err != nil {
-			return fmt.Errorf("failed to read plugin dir %s: %w", pluginBinDir, err)
-		}
-		pluginPattern := regexp.MustCompile("^(jx-.*)-[0-9.]+$")
-		extraPlugins := make(map[string]bool)
-		for _, plugin := range files {
-			res := pluginPattern.FindStringSubmatch(plugin)
-			if len(res) > 1 {
-				cleanPlugin := res[1]
-				if plugins.PluginMap[cleanPlugin] == nil {
-					extraPlugins[cleanPlugin] = true
-				}
-			}
-		}
-		for plugin := range extraPlugins {
-			_, err = plugins.InstallStandardPlugin(pluginBinDir, plugin)
-			if err != nil {
-				log.Logger().Warnf("Failed to upgrade plugin %s: %+v", plugin, err)
-			}
+			return fmt.Errorf("failed to upgrade plugin %s: %w", name, err)
 		}
 	}
+	return nil
+}
+
+func sortedNames(m map[string][]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
 
+// prunePlugins removes the old plugin versions
+func (o *PluginOptions) prunePlugins(pluginBinDir string) error {
+	keep := o.PruneKeep
+	if keep < 1 {
+		keep = plugins.DefaultPruneKeep
+	}
+	prunable, err := plugins.FindPrunablePlugins(pluginBinDir, keep)
+	if err != nil {
+		return fmt.Errorf("failed to find old plugin versions: %w", err)
+	}
+	size, err := plugins.PrunePlugins(prunable)
+	if err != nil {
+		return fmt.Errorf("failed to prune old plugin versions: %w", err)
+	}
+	log.Logger().Infof("reclaimed %s from %d old plugin versions", termcolor.ColorInfo(plugins.FormatBytes(size)), len(prunable))
 	return nil
 }
@@ -1,9 +1,15 @@
 package upgrade_test
 
 import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/jenkins-x/jx/pkg/cmd/upgrade"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,3 +21,29 @@ func TestUpgrade(t *testing.T) {
 	err := cmd.Execute()
 	require.NoError(t, err, "failed to run upgrade command")
 }
+
+func TestUpgradePluginsCheck(t *testing.T) {
+	t.Setenv("JX3_HOME", t.TempDir())
+
+	out := &bytes.Buffer{}
+	_, o := upgrade.NewCmdUpgradePlugins()
+	o.Check = true
+	o.Boot = true
+	o.Output = "json"
+	o.Out = out
+	err := o.Run()
+	require.Error(t, err, "should fail as no plugins are installed")
+	assert.Contains(t, err.Error(), "plugins are out of date")
+
+	var drifts []*plugins.PluginDrift
+	require.NoError(t, json.Unmarshal(out.Bytes(), &drifts))
+	require.NotEmpty(t, drifts)
+	for _, d := range drifts {
+		assert.Equal(t, plugins.DriftActionInstall, d.Action, "plugin %s", d.Name)
+		assert.Empty(t, d.Installed)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(os.Getenv("JX3_HOME"), "plugins", "bin"))
+	require.NoError(t, err)
+	assert.Empty(t, entries, "should not have installed anything")
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// FileName the name of the configuration file in the jx home dir
+	FileName = "config.yaml"
+
+	// FileEnvVar the environment variable used to override the location of the configuration file
+	FileEnvVar = "JX_CONFIG"
+)
+
+// Config the configuration of the jx CLI
+type Config struct {
+	// Mirror the artifact mirror used to lookup and download plugins and the CLI
+	Mirror Mirror `json:"mirror,omitempty"`
+
+	// Hooks the hooks run before and after plugins are invoked
+	Hooks Hooks `json:"hooks,omitempty"`
+
+	// Aliases the user defined command aliases
+	Aliases []Alias `json:"aliases,omitempty"`
+
+	// History the configuration of the command history
+	History History `json:"history,omitempty"`
+
+	// Env the policies which control the environment variables passed to plugins
+	Env Env `json:"env,omitempty"`
+
+	// PluginIndexes the indexes of additional plugins which can be searched and installed
+	PluginIndexes []PluginIndex `json:"pluginIndexes,omitempty"`
+
+	// Offline the configuration of how plugins are resolved without a network connection
+	Offline Offline `json:"offline,omitempty"`
+}
+
+// PluginIndex a source of jenkins.io/v1 Plugin resources which can be searched and installed
+type PluginIndex struct {
+	// Name the name of the index
+	Name string `json:"name"`
+
+	// URL the HTTP URL of a YAML file containing the plugins
+	URL string `json:"url,omitempty"`
+
+	// Git the URL of a git repository containing the plugins
+	Git string `json:"git,omitempty"`
+
+	// Path the YAML file or directory of YAML files in the git repository containing the plugins. Defaults to the root
+	Path string `json:"path,omitempty"`
+}
+
+// Offline the configuration of how plugins are resolved without a network connection
+type Offline struct {
+	// Enabled never downloads plugins, using the installed versions instead
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Compatible which installed versions can be used instead of a pinned version which is not installed.
+	// Supported values are minor (the same major and minor version), major (the same major version) or any.
+	// Defaults to minor
+	Compatible string `json:"compatible,omitempty"`
+}
+
+// History the configuration of the command history
+type History struct {
+	// Disabled disables recording the commands which are run
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Timing records the duration and exit code of plugins by running them as child processes of jx rather than
+	// replacing the jx process
+	Timing bool `json:"timing,omitempty"`
+}
+
+// Alias a user defined command which invokes another jx command or plugin
+type Alias struct {
+	// Name the name of the alias command
+	Name string `json:"name"`
+
+	// Parent the optional space separated path of the parent command such as get
+	Parent string `json:"parent,omitempty"`
+
+	// Args the jx command and arguments the alias invokes such as: [gitops, get, environments]
+	Args []string `json:"args"`
+
+	// Aliases the optional alternative names of the alias command
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Short the optional description of the alias command
+	Short string `json:"short,omitempty"`
+}
+
+// Hooks the executables run before and after a plugin is invoked
+type Hooks struct {
+	// Pre the executables run before a plugin is invoked. If any of them fail the plugin is not invoked
+	Pre []string `json:"pre,omitempty"`
+
+	// Post the executables run after a plugin has completed
+	Post []string `json:"post,omitempty"`
+}
+
+// File returns the location of the configuration file
+func File() (string, error) {
+	path := os.Getenv(FileEnvVar)
+	if path != "" {
+		return path, nil
+	}
+	dir, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Load loads the configuration file if it exists and applies any overrides from environment variables
+func Load() (*Config, error) {
+	path, err := File()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Mirror.applyEnv()
+	return cfg, nil
+}
+
+// LoadFile loads the configuration from the given file returning an empty configuration if it does not exist
+func LoadFile(path string) (*Config, error) {
+	cfg := &Config{}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if file exists %s: %w", path, err)
+	}
+	if !exists {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	err = yaml.Unmarshal(data, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cfg, nil
+}
@@ -0,0 +1,34 @@
+package config
+
+// Env the policies which control the environment variables passed to plugins.
+//
+// Environment variables in the always pass set such as PATH, HOME and KUBECONFIG are passed to every plugin
+type Env struct {
+	// Managed the policy of the managed jenkins-x-plugins. Defaults to passing every environment variable
+	Managed *EnvPolicy `json:"managed,omitempty"`
+
+	// Unmanaged the policy of any other plugins. Defaults to only passing the always pass set
+	Unmanaged *EnvPolicy `json:"unmanaged,omitempty"`
+
+	// Plugins the policies of individual plugins indexed by name such as gitops or jx-gitops which override
+	// the managed or unmanaged policy
+	Plugins map[string]*EnvPolicy `json:"plugins,omitempty"`
+
+	// AlwaysPass additional environment variable name patterns passed to every plugin
+	AlwaysPass []string `json:"alwaysPass,omitempty"`
+}
+
+// EnvPolicy controls which environment variables are passed to a plugin.
+//
+// Patterns are environment variable names which may contain shell style wildcards such as AWS_*
+type EnvPolicy struct {
+	// PassAll passes every environment variable which does not match a deny pattern
+	PassAll bool `json:"passAll,omitempty"`
+
+	// Allow the patterns of the environment variables passed to the plugin
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny the patterns of the environment variables which are never passed to the plugin unless
+	// they are in the always pass set
+	Deny []string `json:"deny,omitempty"`
+}
@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
+)
+
+// HomeDir returns the jx home directory which contains the plugins directory along with
+// the configuration files of the CLI
+func HomeDir() (string, error) {
+	pluginBinDir, err := homedir.DefaultPluginBinDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find plugin bin directory: %w", err)
+	}
+	// the plugin bin dir is always HOME/plugins/bin
+	return filepath.Dir(filepath.Dir(pluginBinDir)), nil
+}
+
+// CacheDir returns the directory in the jx home directory used to cache the results of remote lookups
+func CacheDir() (string, error) {
+	dir, err := HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cache"), nil
+}
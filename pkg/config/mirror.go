@@ -0,0 +1,89 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+const (
+	// GitHubURL the default base URL of GitHub which release binaries are downloaded from
+	GitHubURL = "https://github.com/"
+
+	// GitHubAPIURL the default base URL of the GitHub API used to lookup releases
+	GitHubAPIURL = "https://api.github.com/"
+
+	// MirrorGitHubURLEnvVar the environment variable which overrides the mirror of the GitHub release downloads
+	MirrorGitHubURLEnvVar = "JX_MIRROR_GITHUB_URL"
+
+	// MirrorGitHubAPIURLEnvVar the environment variable which overrides the GitHub API base URL
+	MirrorGitHubAPIURLEnvVar = "JX_MIRROR_GITHUB_API_URL"
+
+	// GitHubEnterpriseHostEnvVar the environment variable which overrides the GitHub Enterprise host GitHub tokens are sent to
+	GitHubEnterpriseHostEnvVar = "JX_GITHUB_ENTERPRISE_HOST"
+
+	githubAPIHost = "api.github.com"
+)
+
+// Mirror rewrites the URLs used to lookup and download releases so that they can be served
+// from an artifact mirror such as Artifactory or Nexus in air-gapped environments
+type Mirror struct {
+	// GitHubURL the base URL which replaces https://github.com/ when downloading release binaries
+	// such as https://artifactory.acme.com/artifactory/github/
+	GitHubURL string `json:"githubURL,omitempty"`
+
+	// GitHubAPIURL the base URL which replaces https://api.github.com/ when looking up releases
+	// such as the GitHub Enterprise API https://github.acme.com/api/v3/
+	GitHubAPIURL string `json:"githubAPIURL,omitempty"`
+
+	// GitHubEnterpriseHost the GitHub Enterprise host such as github.acme.com which GitHub tokens are sent to.
+	// Tokens are only sent to api.github.com otherwise so that a mirror never receives them
+	GitHubEnterpriseHost string `json:"githubEnterpriseHost,omitempty"`
+}
+
+func (m *Mirror) applyEnv() {
+	if v := os.Getenv(MirrorGitHubURLEnvVar); v != "" {
+		m.GitHubURL = v
+	}
+	if v := os.Getenv(MirrorGitHubAPIURLEnvVar); v != "" {
+		m.GitHubAPIURL = v
+	}
+	if v := os.Getenv(GitHubEnterpriseHostEnvVar); v != "" {
+		m.GitHubEnterpriseHost = v
+	}
+}
+
+// IsTokenHost returns true if GitHub tokens can be sent to the host of a GitHub API URL
+func (m *Mirror) IsTokenHost(host string) bool {
+	if host == githubAPIHost {
+		return true
+	}
+	if m == nil || m.GitHubEnterpriseHost == "" {
+		return false
+	}
+	gheHost := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(m.GitHubEnterpriseHost, "https://"), "http://"), "/")
+	return host == gheHost
+}
+
+// DownloadURL rewrites a GitHub release download URL to use the mirror if one is configured
+func (m *Mirror) DownloadURL(u string) string {
+	if m == nil || m.GitHubURL == "" || !strings.HasPrefix(u, GitHubURL) {
+		return u
+	}
+	return withTrailingSlash(m.GitHubURL) + strings.TrimPrefix(u, GitHubURL)
+}
+
+// APIURL returns the URL of the given path in the GitHub API such as repos/jenkins-x/jx/releases/latest
+func (m *Mirror) APIURL(path string) string {
+	base := GitHubAPIURL
+	if m != nil && m.GitHubAPIURL != "" {
+		base = withTrailingSlash(m.GitHubAPIURL)
+	}
+	return base + strings.TrimPrefix(path, "/")
+}
+
+func withTrailingSlash(u string) string {
+	if strings.HasSuffix(u, "/") {
+		return u
+	}
+	return u + "/"
+}
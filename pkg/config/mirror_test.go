@@ -0,0 +1,48 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMirrorURLs(t *testing.T) {
+	t.Parallel()
+
+	var m *config.Mirror
+	u := "https://github.com/jenkins-x/jx/releases/download/v3.0.0/jx-linux-amd64.tar.gz"
+	assert.Equal(t, u, m.DownloadURL(u), "nil mirror should not rewrite")
+	assert.Equal(t, "https://api.github.com/repos/jenkins-x/jx/releases/latest", m.APIURL("repos/jenkins-x/jx/releases/latest"))
+
+	m = &config.Mirror{
+		GitHubURL:    "https://artifactory.acme.com/github",
+		GitHubAPIURL: "https://github.acme.com/api/v3/",
+	}
+	assert.Equal(t, "https://artifactory.acme.com/github/jenkins-x/jx/releases/download/v3.0.0/jx-linux-amd64.tar.gz", m.DownloadURL(u))
+	assert.Equal(t, "https://example.com/foo.tar.gz", m.DownloadURL("https://example.com/foo.tar.gz"), "should only rewrite GitHub URLs")
+	assert.Equal(t, "https://github.acme.com/api/v3/repos/jenkins-x/jx/releases/latest", m.APIURL("/repos/jenkins-x/jx/releases/latest"))
+	assert.False(t, m.IsTokenHost("github.acme.com"), "should not send tokens to a mirror which is not configured as a GitHub Enterprise host")
+	assert.True(t, m.IsTokenHost("api.github.com"))
+
+	m.GitHubEnterpriseHost = "https://github.acme.com/"
+	assert.True(t, m.IsTokenHost("github.acme.com"))
+	assert.False(t, m.IsTokenHost("artifactory.acme.com"))
+}
+
+func TestLoadMirrorFromFileAndEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("mirror:\n  githubURL: https://file.acme.com/github/\n  githubAPIURL: https://file.acme.com/api/\n"), 0o600)
+	require.NoError(t, err)
+
+	t.Setenv(config.FileEnvVar, path)
+	t.Setenv(config.MirrorGitHubAPIURLEnvVar, "https://env.acme.com/api/")
+
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "https://file.acme.com/github/", cfg.Mirror.GitHubURL)
+	assert.Equal(t, "https://env.acme.com/api/", cfg.Mirror.GitHubAPIURL, "environment variables should override the file")
+}
@@ -0,0 +1,320 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	// FileName the name of the history file in the jx home dir
+	FileName = "history.jsonl"
+
+	// DisableEnvVar the environment variable which disables recording the history if set to true
+	DisableEnvVar = "JX_NO_HISTORY"
+
+	// DefaultMaxSize the size of the history file in bytes at which it is rotated
+	DefaultMaxSize int64 = 1024 * 1024
+
+	// lockTimeout how long to wait for another jx process to finish appending to the history file
+	lockTimeout = 2 * time.Second
+
+	// staleLockAge the age at which a lock file is assumed to have been left behind by a process which died
+	staleLockAge = 30 * time.Second
+
+	lockRetryInterval = 10 * time.Millisecond
+
+	fileMode os.FileMode = 0o600
+)
+
+// Entry a command which was run
+type Entry struct {
+	// ID the sequence number of the entry used to re-run it
+	ID int `json:"id"`
+
+	// Time when the command started
+	Time time.Time `json:"time"`
+
+	// Args the redacted command line arguments excluding the jx binary
+	Args []string `json:"args"`
+
+	// Plugin the name of the plugin binary invoked such as jx-gitops if the command was a plugin
+	Plugin string `json:"plugin,omitempty"`
+
+	// PluginPath the path of the plugin binary invoked
+	PluginPath string `json:"pluginPath,omitempty"`
+
+	// PluginVersion the version of the plugin invoked if known
+	PluginVersion string `json:"pluginVersion,omitempty"`
+
+	// Dir the working directory the command was run in
+	Dir string `json:"dir,omitempty"`
+
+	// KubeContext the current kubernetes context when the command was run
+	KubeContext string `json:"kubeContext,omitempty"`
+
+	// Duration how long the command took
+	Duration time.Duration `json:"duration"`
+
+	// ExitCode the exit code of the command
+	ExitCode int `json:"exitCode"`
+
+	// Exec true if the plugin replaced the jx process so the duration and exit code are not known
+	Exec bool `json:"exec,omitempty"`
+}
+
+// File returns the location of the history file
+func File() (string, error) {
+	dir, err := config.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, FileName), nil
+}
+
+// Disabled returns true if recording the history has been disabled via the environment or the configuration
+func Disabled(cfg *config.Config) bool {
+	if strings.EqualFold(os.Getenv(DisableEnvVar), "true") {
+		return true
+	}
+	return cfg != nil && cfg.History.Disabled
+}
+
+// Recorder records a single command to the history file. A nil recorder records nothing
+type Recorder struct {
+	// Path the history file
+	Path string
+
+	// MaxSize the size in bytes at which the history file is rotated
+	MaxSize int64
+
+	// Timing runs plugins as child processes so that their duration and exit code can be recorded
+	Timing bool
+
+	entry    Entry
+	start    time.Time
+	finished sync.Once
+}
+
+// NewRecorder creates a recorder for the history file in the jx home dir or returns nil if the history is disabled
+func NewRecorder(cfg *config.Config) *Recorder {
+	if Disabled(cfg) {
+		return nil
+	}
+	path, err := File()
+	if err != nil {
+		log.Logger().Debugf("not recording history: %s", err.Error())
+		return nil
+	}
+	return &Recorder{Path: path, MaxSize: DefaultMaxSize, Timing: cfg != nil && cfg.History.Timing}
+}
+
+// Timed returns true if plugins should run as child processes so that their duration and exit code are recorded
+func (r *Recorder) Timed() bool {
+	return r != nil && r.Timing
+}
+
+// Start starts timing the command with the given arguments. Only the first call has any effect
+func (r *Recorder) Start(args []string) {
+	if r == nil || !r.start.IsZero() {
+		return
+	}
+	r.start = time.Now()
+	r.entry = Entry{
+		Time:        r.start.UTC(),
+		Args:        RedactArgs(args),
+		KubeContext: currentKubeContext(),
+	}
+	r.entry.Dir, _ = os.Getwd()
+}
+
+// SetPlugin records the plugin binary which the command invoked
+func (r *Recorder) SetPlugin(name, path, version string) {
+	if r == nil {
+		return
+	}
+	r.entry.Plugin = name
+	r.entry.PluginPath = path
+	r.entry.PluginVersion = version
+}
+
+// Finish records the command with the given exit code. Only the first call has any effect
+func (r *Recorder) Finish(exitCode int) {
+	if r == nil || r.start.IsZero() {
+		return
+	}
+	r.finished.Do(func() {
+		r.entry.Duration = time.Since(r.start)
+		r.entry.ExitCode = exitCode
+		err := Append(r.Path, &r.entry, r.MaxSize)
+		if err != nil {
+			log.Logger().Debugf("failed to record history: %s", err.Error())
+		}
+	})
+}
+
+// Exec records the command just before the plugin replaces the jx process so its duration and exit code are not known
+func (r *Recorder) Exec() {
+	if r == nil {
+		return
+	}
+	r.entry.Exec = true
+	r.Finish(0)
+}
+
+// Append appends the entry to the history file assigning its ID and rotating the file if it exceeds the max size.
+//
+// The history file is locked so that concurrent jx processes never assign the same ID
+func Append(path string, entry *Entry, maxSize int64) error {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create dir for %s: %w", path, err)
+	}
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	entry.ID, err = lastID(path)
+	if err != nil {
+		return err
+	}
+	entry.ID++
+
+	if info, err := os.Stat(path); err == nil && maxSize > 0 && info.Size() >= maxSize {
+		err = os.Rename(path, path+".1")
+		if err != nil {
+			return fmt.Errorf("failed to rotate %s: %w", path, err)
+		}
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history entry: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	_, err = f.Write(append(data, '\n'))
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// Load loads the entries in the history file along with its rotated file, oldest first
+func Load(path string) ([]*Entry, error) {
+	var answer []*Entry
+	for _, p := range []string{path + ".1", path} {
+		entries, err := loadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		answer = append(answer, entries...)
+	}
+	return answer, nil
+}
+
+// lastID returns the ID of the last entry in the history file or its rotated file if it is empty
+func lastID(path string) (int, error) {
+	for _, p := range []string{path, path + ".1"} {
+		entries, err := loadFile(p)
+		if err != nil {
+			return 0, err
+		}
+		if len(entries) > 0 {
+			return entries[len(entries)-1].ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// lock creates the lock file waiting for any other process holding it, returning the function to release it
+func lock(path string) (func(), error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, fileMode)
+		if err == nil {
+			f.Close()
+			return func() {
+				os.Remove(path)
+			}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file %s: %w", path, err)
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			log.Logger().Debugf("removing stale history lock file %s", path)
+			os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for lock file %s", path)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Find returns the entry with the given ID or nil if it does not exist
+func Find(entries []*Entry, id int) *Entry {
+	for _, e := range entries {
+		if e.ID == id {
+			return e
+		}
+	}
+	return nil
+}
+
+func loadFile(path string) ([]*Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var answer []*Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, int(DefaultMaxSize))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		entry := &Entry{}
+		err = json.Unmarshal([]byte(line), entry)
+		if err != nil {
+			// lets skip any partially written lines
+			continue
+		}
+		answer = append(answer, entry)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return answer, nil
+}
+
+func currentKubeContext() string {
+	cfg, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil || cfg == nil {
+		return ""
+	}
+	return cfg.CurrentContext
+}
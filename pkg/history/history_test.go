@@ -0,0 +1,120 @@
+package history_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/history"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), history.FileName)
+	r := &history.Recorder{Path: path, MaxSize: history.DefaultMaxSize}
+	r.Start([]string{"gitops", "--token", "abc"})
+	r.SetPlugin("jx-gitops", "/bin/jx-gitops-1.2.3", "1.2.3")
+	r.Finish(3)
+	r.Finish(0)
+
+	r = &history.Recorder{Path: path, MaxSize: history.DefaultMaxSize}
+	r.Start([]string{"version"})
+	r.Finish(0)
+
+	entries, err := history.Load(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	e := entries[0]
+	assert.Equal(t, 1, e.ID)
+	assert.Equal(t, []string{"gitops", "--token", history.Redacted}, e.Args)
+	assert.Equal(t, "jx-gitops", e.Plugin)
+	assert.Equal(t, "1.2.3", e.PluginVersion)
+	assert.Equal(t, 3, e.ExitCode)
+	assert.NotEmpty(t, e.Dir)
+
+	assert.Equal(t, 2, entries[1].ID)
+	assert.Equal(t, entries[1], history.Find(entries, 2))
+	assert.Nil(t, history.Find(entries, 3))
+}
+
+func TestRecorderRotates(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), history.FileName)
+	for i := 0; i < 3; i++ {
+		err := history.Append(path, &history.Entry{Args: []string{"version"}}, 1)
+		require.NoError(t, err)
+	}
+	assert.FileExists(t, path+".1")
+
+	entries, err := history.Load(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 2, "should only keep the current and the rotated file")
+	assert.Equal(t, 2, entries[0].ID)
+	assert.Equal(t, 3, entries[1].ID)
+}
+
+func TestAppendConcurrently(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), history.FileName)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := history.Append(path, &history.Entry{Args: []string{"version"}}, history.DefaultMaxSize)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	entries, err := history.Load(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+	ids := map[int]bool{}
+	for _, e := range entries {
+		ids[e.ID] = true
+	}
+	assert.Len(t, ids, 10, "should assign unique IDs")
+	assert.NoFileExists(t, path+".lock")
+}
+
+func TestRecorderExec(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), history.FileName)
+	r := &history.Recorder{Path: path, MaxSize: history.DefaultMaxSize}
+	r.Start([]string{"gitops", "upgrade"})
+	r.Exec()
+	r.Finish(1)
+
+	entries, err := history.Load(path)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].Exec)
+	assert.Equal(t, 0, entries[0].ExitCode)
+	assert.False(t, r.Timed())
+}
+
+func TestNilRecorder(t *testing.T) {
+	t.Setenv(history.DisableEnvVar, "true")
+
+	r := history.NewRecorder(&config.Config{})
+	require.Nil(t, r)
+	r.Start([]string{"version"})
+	r.SetPlugin("jx-gitops", "", "")
+	r.Exec()
+	r.Finish(0)
+	assert.False(t, r.Timed())
+
+	t.Setenv(history.DisableEnvVar, "")
+	assert.True(t, history.NewRecorder(&config.Config{History: config.History{Timing: true}}).Timed())
+	assert.True(t, history.Disabled(&config.Config{History: config.History{Disabled: true}}))
+	assert.False(t, history.Disabled(&config.Config{}))
+}
@@ -0,0 +1,93 @@
+package history
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted the value which replaces secret looking values
+const Redacted = "*****"
+
+var (
+	secretNamePattern = regexp.MustCompile(`(?i)(pass(word|wd)?|secret|token|api[-_]?key|private[-_]?key|credential|auth)`)
+
+	secretValuePatterns = []*regexp.Regexp{
+		// GitHub, GitLab and Slack tokens
+		regexp.MustCompile(`^(gh[pousr]_|github_pat_|glpat-|xox[abpr]-)[A-Za-z0-9_-]+$`),
+		// AWS access keys
+		regexp.MustCompile(`^(AKIA|ASIA)[0-9A-Z]{16}$`),
+		// JWTs
+		regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`),
+	}
+
+	// randomPattern matches long strings such as hex or base64 encoded keys
+	randomPattern = regexp.MustCompile(`^[A-Za-z0-9+/_-]{40,}={0,2}$`)
+	digitPattern  = regexp.MustCompile(`[0-9]`)
+	letterPattern = regexp.MustCompile(`[A-Za-z]`)
+)
+
+// RedactArgs returns a copy of the command line arguments with any secret looking values redacted
+func RedactArgs(args []string) []string {
+	answer := make([]string, 0, len(args))
+	redactNext := false
+	for _, arg := range args {
+		switch {
+		case redactNext:
+			redactNext = false
+			arg = Redacted
+		case strings.HasPrefix(arg, "-"):
+			name, _, hasValue := strings.Cut(arg, "=")
+			if secretNamePattern.MatchString(name) {
+				if hasValue {
+					arg = name + "=" + Redacted
+				} else if !isBoolFlag(name) {
+					redactNext = true
+				}
+			} else if hasValue {
+				arg = name + "=" + RedactValue(arg[len(name)+1:])
+			}
+		default:
+			arg = RedactValue(arg)
+		}
+		answer = append(answer, arg)
+	}
+	return answer
+}
+
+// RedactValue redacts the value if it looks like a secret, a KEY=VALUE pair with a secret looking key
+// or a URL containing a password
+func RedactValue(value string) string {
+	if key, v, ok := strings.Cut(value, "="); ok && v != "" && secretNamePattern.MatchString(key) {
+		return key + "=" + Redacted
+	}
+	for _, p := range secretValuePatterns {
+		if p.MatchString(value) {
+			return Redacted
+		}
+	}
+	if looksRandom(value) {
+		return Redacted
+	}
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err == nil && u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				u.User = url.User(u.User.Username())
+				return strings.Replace(u.String(), u.User.String()+"@", u.User.String()+":"+Redacted+"@", 1)
+			}
+		}
+	}
+	return value
+}
+
+// looksRandom returns true if the value looks like a generated key rather than a name or path
+func looksRandom(value string) bool {
+	return !strings.HasPrefix(value, "/") && randomPattern.MatchString(value) && digitPattern.MatchString(value) && letterPattern.MatchString(value)
+}
+
+// isBoolFlag returns true for common boolean flags which mention secrets but do not take a value
+func isBoolFlag(name string) bool {
+	name = strings.TrimLeft(name, "-")
+	return strings.HasPrefix(name, "no-") || strings.HasPrefix(name, "skip-") || strings.HasSuffix(name, "-enabled") || strings.HasPrefix(name, "insecure")
+}
@@ -0,0 +1,263 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// RefreshCompletionArg the hidden jx argument used to refresh cached plugin completions in the background
+	RefreshCompletionArg = "__jx_refresh_completion"
+
+	// DefaultCompletionTimeout how long a plugin is given to print its completions while the user waits
+	DefaultCompletionTimeout = 2 * time.Second
+
+	// DefaultCompletionRefreshTimeout how long a plugin is given to print its completions in the background
+	DefaultCompletionRefreshTimeout = 30 * time.Second
+
+	// DefaultCompletionTTL how long cached completions are used without being refreshed
+	DefaultCompletionTTL = 30 * time.Second
+
+	// DefaultCompletionStaleTTL how long cached completions are used while they are refreshed in the background
+	DefaultCompletionStaleTTL = 10 * time.Minute
+
+	completionWaitDelay = 100 * time.Millisecond
+)
+
+// PluginCompleter calls plugins to complete their arguments caching the results so that repeated completions are instant
+type PluginCompleter struct {
+	// CacheDir the directory completions are cached in or empty to disable caching
+	CacheDir string
+
+	// Timeout how long a plugin is given to print its completions while the user waits
+	Timeout time.Duration
+
+	// TTL how long cached completions are used without being refreshed
+	TTL time.Duration
+
+	// StaleTTL how long cached completions are used while they are refreshed in the background
+	StaleTTL time.Duration
+
+	// Refresh starts refreshing the cached completions of the plugin arguments in the background
+	Refresh func(path string, args []string)
+
+	// Env the policies of the environment variables passed to the plugins
+	Env *config.Env
+}
+
+// completionCacheEntry the cached completions of a plugin
+type completionCacheEntry struct {
+	Completions []string                 `json:"completions,omitempty"`
+	Directive   cobra.ShellCompDirective `json:"directive"`
+	Fetched     time.Time                `json:"fetched"`
+}
+
+// NewPluginCompleter creates a plugin completer which caches completions in the jx cache dir
+// and refreshes stale completions in a background jx process
+func NewPluginCompleter() *PluginCompleter {
+	c := &PluginCompleter{
+		Timeout:  DefaultCompletionTimeout,
+		TTL:      DefaultCompletionTTL,
+		StaleTTL: DefaultCompletionStaleTTL,
+		Refresh:  refreshInBackground,
+	}
+	env, err := loadEnv()
+	if err != nil {
+		cobra.CompDebugln("using the default plugin environment policies: "+err.Error(), false)
+	}
+	c.Env = env
+	dir, err := config.CacheDir()
+	if err != nil {
+		cobra.CompDebugln("not caching plugin completions: "+err.Error(), false)
+		return c
+	}
+	c.CacheDir = filepath.Join(dir, "completion")
+	return c
+}
+
+// Complete returns the completions of the plugin for the given arguments.
+//
+// The plugin is asked for all the completions of the word being completed which are cached and then filtered
+// so that typing more characters and pressing TAB again does not invoke the plugin again
+func (c *PluginCompleter) Complete(path string, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if c.CacheDir == "" || strings.Contains(toComplete, "=") {
+		comps, directive, _ := c.fetch(path, append(append([]string{}, args...), toComplete), c.Timeout)
+		return comps, directive
+	}
+
+	// flags are only completed by cobra when the word starts with a dash
+	word := ""
+	if strings.HasPrefix(toComplete, "-") {
+		word = "-"
+	}
+	keyArgs := append(append([]string{}, args...), word)
+	cacheFile := c.cacheFile(path, keyArgs)
+
+	entry := &completionCacheEntry{}
+	if readJSONFile(cacheFile, entry) {
+		age := time.Since(entry.Fetched)
+		if age < c.StaleTTL {
+			if age >= c.TTL {
+				c.refresh(path, keyArgs, cacheFile)
+			}
+			return filterCompletions(entry.Completions, toComplete), entry.Directive
+		}
+	}
+
+	entry, err := c.fetchAndCache(path, keyArgs, c.Timeout)
+	if err != nil {
+		cobra.CompDebugln("failed to complete plugin "+path+": "+err.Error(), false)
+		if errors.Is(err, context.DeadlineExceeded) {
+			// lets carry on in the background so the next completion is instant
+			c.refresh(path, keyArgs, cacheFile)
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return filterCompletions(entry.Completions, toComplete), entry.Directive
+}
+
+// fetchAndCache invokes the plugin to complete the arguments, the last of which is the word being completed,
+// and caches the result
+func (c *PluginCompleter) fetchAndCache(path string, args []string, timeout time.Duration) (*completionCacheEntry, error) {
+	comps, directive, err := c.fetch(path, args, timeout)
+	if err != nil {
+		return nil, err
+	}
+	entry := &completionCacheEntry{
+		Completions: comps,
+		Directive:   directive,
+		Fetched:     time.Now(),
+	}
+	if c.CacheDir != "" {
+		writeJSONFile(c.cacheFile(path, args), entry)
+	}
+	return entry, nil
+}
+
+// fetch invokes the plugin with a deadline to complete the arguments
+func (c *PluginCompleter) fetch(path string, args []string, timeout time.Duration) ([]string, cobra.ShellCompDirective, error) {
+	if timeout <= 0 {
+		timeout = DefaultCompletionTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	newArgs := append([]string{cobra.ShellCompRequestCmd}, args...)
+	cobra.CompDebugln("About to call: "+path+" "+strings.Join(newArgs, " "), true)
+	return getPluginCompletions(ctx, path, newArgs, PluginEnv(c.Env, path, os.Environ()))
+}
+
+// refresh starts refreshing the cached completions unless a refresh is already in progress
+func (c *PluginCompleter) refresh(path string, args []string, cacheFile string) {
+	if c.Refresh == nil {
+		return
+	}
+	marker := cacheFile + ".refresh"
+	if fi, err := os.Stat(marker); err == nil && time.Since(fi.ModTime()) < DefaultCompletionRefreshTimeout {
+		return
+	}
+	writeJSONFile(marker, time.Now())
+	c.Refresh(path, args)
+}
+
+// cacheFile returns the cache file for the plugin path and arguments
+func (c *PluginCompleter) cacheFile(path string, args []string) string {
+	key := Checksum([]byte(path + "\x00" + strings.Join(args, "\x00")))
+	return filepath.Join(c.CacheDir, key+".json")
+}
+
+// RefreshCompletion refreshes the cached completions of a plugin. It is invoked by a background jx process
+// with the plugin path followed by the arguments to complete
+func RefreshCompletion(args []string) error {
+	if len(args) < 2 { //nolint:mnd
+		return nil
+	}
+	c := NewPluginCompleter()
+	defer os.Remove(c.cacheFile(args[0], args[1:]) + ".refresh") //nolint:errcheck
+	_, err := c.fetchAndCache(args[0], args[1:], DefaultCompletionRefreshTimeout)
+	return err
+}
+
+// refreshInBackground starts a detached jx process to refresh the cached completions
+func refreshInBackground(path string, args []string) {
+	exe, err := os.Executable()
+	if err != nil {
+		return
+	}
+	cmd := exec.Command(exe, append([]string{RefreshCompletionArg, path}, args...)...)
+	cmd.Env = os.Environ()
+	err = cmd.Start()
+	if err != nil {
+		cobra.CompDebugln("failed to refresh plugin completions: "+err.Error(), false)
+		return
+	}
+	cmd.Process.Release() //nolint:errcheck
+}
+
+// filterCompletions returns the completions which start with the word being completed
+func filterCompletions(comps []string, toComplete string) []string {
+	var answer []string
+	for _, comp := range comps {
+		value, _, _ := strings.Cut(comp, "\t")
+		if strings.HasPrefix(value, toComplete) {
+			answer = append(answer, comp)
+		}
+	}
+	return answer
+}
+
+// getPluginCompletions receives an executable's filepath, a slice
+// of arguments, and a slice of environment variables
+// to relay to the executable.
+func getPluginCompletions(ctx context.Context, executablePath string, cmdArgs, environment []string) ([]string, cobra.ShellCompDirective, error) {
+	buf := new(bytes.Buffer)
+
+	prog := exec.CommandContext(ctx, executablePath, cmdArgs...)
+	prog.Stdin = os.Stdin
+	prog.Stdout = buf
+	prog.Stderr = os.Stderr
+	prog.Env = environment
+	// lets not wait for any processes started by the plugin which keep its output open
+	prog.WaitDelay = completionWaitDelay
+
+	var comps []string
+	directive := cobra.ShellCompDirectiveNoFileComp
+	err := prog.Run()
+	if ctx.Err() != nil {
+		return nil, directive, ctx.Err()
+	}
+	if err != nil {
+		return nil, directive, err
+	}
+	for _, comp := range strings.Split(buf.String(), "\n") {
+		// Remove any empty lines
+		if comp != "" {
+			comps = append(comps, comp)
+		}
+	}
+
+	// Check if the last line of output is of the form :<integer>, which
+	// indicates a Cobra ShellCompDirective.  We do this for plugins
+	// that use Cobra or the ones that wish to use this directive to
+	// communicate a special behavior for the shell.
+	if len(comps) > 0 {
+		lastLine := comps[len(comps)-1]
+		if len(lastLine) > 1 && lastLine[0] == ':' {
+			if strInt, err := strconv.Atoi(lastLine[1:]); err == nil {
+				directive = cobra.ShellCompDirective(strInt)
+				comps = comps[:len(comps)-1]
+			}
+		}
+	}
+	return comps, directive, nil
+}
@@ -0,0 +1,109 @@
+package plugins_test
+
+import (
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createCompletionPlugin creates a plugin which logs each invocation and prints completions after the given delay
+func createCompletionPlugin(t *testing.T, delay string) (string, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jx-foo")
+	logFile := filepath.Join(dir, "invocations.log")
+	writeScript(t, path, "echo \"$@\" >> "+logFile+"\nsleep "+delay+"\nprintf 'alpha\\nbeta\\tthe beta\\nalbert\\n:4\\n'\n")
+	return path, logFile
+}
+
+func TestPluginCompleterCaches(t *testing.T) {
+	t.Parallel()
+
+	path, logFile := createCompletionPlugin(t, "0")
+	c := &plugins.PluginCompleter{
+		CacheDir: t.TempDir(),
+		TTL:      time.Hour,
+		StaleTTL: time.Hour,
+	}
+
+	comps, directive := c.Complete(path, []string{"get"}, "al")
+	assert.Equal(t, []string{"alpha", "albert"}, comps)
+	assert.Equal(t, cobra.ShellCompDirectiveNoFileComp, directive)
+
+	comps, _ = c.Complete(path, []string{"get"}, "b")
+	assert.Equal(t, []string{"beta\tthe beta"}, comps)
+
+	comps, _ = c.Complete(path, []string{"get"}, "alb")
+	assert.Equal(t, []string{"albert"}, comps)
+
+	assert.Equal(t, 1, invocations(t, logFile), "should only invoke the plugin once for the same args prefix")
+
+	c.Complete(path, []string{"delete"}, "")
+	assert.Equal(t, 2, invocations(t, logFile), "should invoke the plugin for different args")
+}
+
+func TestPluginCompleterFiltersEnv(t *testing.T) {
+	t.Setenv("JX_TEST_SECRET", "s3cret")
+
+	path := filepath.Join(t.TempDir(), "jx-foo")
+	writeScript(t, path, "echo \"secret=$JX_TEST_SECRET\"\n")
+	c := &plugins.PluginCompleter{}
+
+	comps, _ := c.Complete(path, nil, "")
+	assert.Equal(t, []string{"secret="}, comps, "should not pass the environment to unmanaged plugins by default")
+
+	c.Env = &config.Env{Plugins: map[string]*config.EnvPolicy{"foo": {Allow: []string{"JX_TEST_SECRET"}}}}
+	comps, _ = c.Complete(path, nil, "")
+	assert.Equal(t, []string{"secret=s3cret"}, comps, "should use the environment policy of the plugin")
+}
+
+func TestPluginCompleterTimeout(t *testing.T) {
+	t.Parallel()
+
+	path, _ := createCompletionPlugin(t, "2")
+	var refreshed atomic.Int32
+	c := &plugins.PluginCompleter{
+		CacheDir: t.TempDir(),
+		Timeout:  100 * time.Millisecond,
+		TTL:      time.Hour,
+		StaleTTL: time.Hour,
+		Refresh: func(_ string, _ []string) {
+			refreshed.Add(1)
+		},
+	}
+
+	start := time.Now()
+	comps, _ := c.Complete(path, []string{"get"}, "")
+	assert.Less(t, time.Since(start), time.Second, "should not wait for the slow plugin")
+	assert.Empty(t, comps)
+	assert.Equal(t, int32(1), refreshed.Load(), "should refresh in the background after timing out")
+}
+
+func TestPluginCompleterStaleWhileRevalidate(t *testing.T) {
+	t.Parallel()
+
+	path, logFile := createCompletionPlugin(t, "0")
+	var refreshArgs []string
+	c := &plugins.PluginCompleter{
+		CacheDir: t.TempDir(),
+		TTL:      time.Nanosecond,
+		StaleTTL: time.Hour,
+		Refresh: func(_ string, args []string) {
+			refreshArgs = args
+		},
+	}
+
+	comps, _ := c.Complete(path, []string{"get"}, "")
+	require.Len(t, comps, 3)
+
+	comps, _ = c.Complete(path, []string{"get"}, "be")
+	assert.Equal(t, []string{"beta\tthe beta"}, comps, "should use the stale completions")
+	assert.Equal(t, []string{"get", ""}, refreshArgs, "should refresh the stale completions")
+	assert.Equal(t, 1, invocations(t, logFile))
+}
@@ -0,0 +1,144 @@
+package plugins
+
+import (
+	"strings"
+
+	"github.com/blang/semver"
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+)
+
+const (
+	// DriftActionNone the plugin is up to date
+	DriftActionNone = "none"
+
+	// DriftActionInstall the plugin is not installed
+	DriftActionInstall = "install"
+
+	// DriftActionUpgrade a different version of the plugin is installed
+	DriftActionUpgrade = "upgrade"
+
+	// DriftActionUnknown the target version of the plugin could not be found
+	DriftActionUnknown = "unknown"
+)
+
+// PluginDrift compares the installed version of a plugin with the version upgrading would install
+type PluginDrift struct {
+	// Name the binary name of the plugin such as jx-gitops
+	Name string `json:"name"`
+
+	// Managed true if the plugin version is pinned by this version of jx
+	Managed bool `json:"managed"`
+
+	// Installed the newest version installed in the plugin bin dir if any
+	Installed string `json:"installed,omitempty"`
+
+	// Target the version upgrading would install
+	Target string `json:"target,omitempty"`
+
+	// Action the action upgrading would take
+	Action string `json:"action"`
+
+	// Error why the target version could not be found
+	Error string `json:"error,omitempty"`
+}
+
+// IsOutdated returns true if upgrading would change the plugin or its state is unknown
+func (d *PluginDrift) IsOutdated() bool {
+	return d.Action != DriftActionNone
+}
+
+// Drift compares the given managed plugins and, if extras is true, the latest releases of the other plugins in
+// the plugin bin dir against the installed versions without installing anything
+func (i *Installer) Drift(pluginBinDir string, managed []jenkinsv1.Plugin, extras bool) ([]*PluginDrift, error) {
+	installed, err := InstalledPlugins(pluginBinDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var answer []*PluginDrift
+	for k := range managed {
+		spec := &managed[k].Spec
+		d := &PluginDrift{
+			Name:    spec.Name,
+			Managed: true,
+			Target:  spec.Version,
+		}
+		versions := installed[spec.Name]
+		if len(versions) > 0 {
+			d.Installed = versions[0]
+		}
+		switch {
+		case contains(versions, spec.Version):
+			// the pinned version is used even if newer versions are installed
+			d.Installed = spec.Version
+			d.Action = DriftActionNone
+		case len(versions) == 0:
+			d.Action = DriftActionInstall
+		default:
+			d.Action = DriftActionUpgrade
+		}
+		answer = append(answer, d)
+	}
+	if !extras {
+		return answer, nil
+	}
+
+	for _, name := range sortedKeys(installed) {
+		if PluginMap[name] != nil {
+			continue
+		}
+		d := &PluginDrift{
+			Name:      name,
+			Installed: installed[name][0],
+		}
+		target, err := i.latestVersion(name)
+		if err != nil {
+			d.Action = DriftActionUnknown
+			d.Error = err.Error()
+			answer = append(answer, d)
+			continue
+		}
+		d.Target = target
+		d.Action = DriftActionNone
+		if !isSameOrNewer(d.Installed, d.Target) {
+			d.Action = DriftActionUpgrade
+		}
+		answer = append(answer, d)
+	}
+	return answer, nil
+}
+
+// isSameOrNewer returns true if the installed version is the same or newer than the target version
+func isSameOrNewer(installed, target string) bool {
+	iv, err := semver.Parse(installed)
+	if err != nil {
+		return installed == target
+	}
+	tv, err := semver.Parse(target)
+	if err != nil {
+		return installed == target
+	}
+	return iv.GTE(tv)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// latestVersion returns the latest version of an unmanaged plugin from the repository it was installed from
+// defaulting to the jenkins-x-plugins organisation
+func (i *Installer) latestVersion(binaryName string) (string, error) {
+	if src := i.Sources.Find(binaryName); src != nil {
+		return i.LatestSourceVersion(src)
+	}
+	tagName, err := i.LatestRelease(jenkinsxPluginsOrganisation, binaryName)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(tagName, "v"), nil
+}
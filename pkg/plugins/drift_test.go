@@ -0,0 +1,63 @@
+package plugins_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrift(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/jenkins-x-plugins/jx-old/releases/latest":
+			w.Write([]byte(`{"tag_name": "v2.0.0"}`)) //nolint:errcheck
+		case "/repos/jenkins-x-plugins/jx-current/releases/latest":
+			w.Write([]byte(`{"tag_name": "v1.0.0"}`)) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	dir := createPluginBinaries(t,
+		"jx-gitops-"+plugins.GitOpsVersion, "jx-gitops-99.0.0",
+		"jx-secret-0.0.1",
+		"jx-old-1.0.0", "jx-current-1.0.0", "jx-missing-1.0.0")
+	installer := &plugins.Installer{
+		Mirror: &config.Mirror{GitHubAPIURL: server.URL},
+		Client: server.Client(),
+		Token:  "my-token",
+	}
+
+	var managed []jenkinsv1.Plugin
+	for _, name := range []string{"jx-gitops", "jx-secret", "jx-health"} {
+		managed = append(managed, *plugins.PluginMap[name])
+	}
+	drifts, err := installer.Drift(dir, managed, true)
+	require.NoError(t, err)
+
+	actions := map[string]string{}
+	for _, d := range drifts {
+		actions[d.Name] = d.Action + " " + d.Installed + " -> " + d.Target
+	}
+	assert.Equal(t, map[string]string{
+		"jx-gitops":  "none " + plugins.GitOpsVersion + " -> " + plugins.GitOpsVersion,
+		"jx-secret":  "upgrade 0.0.1 -> " + plugins.SecretVersion,
+		"jx-health":  "install  -> " + plugins.HealthVersion,
+		"jx-current": "none 1.0.0 -> 1.0.0",
+		"jx-missing": "unknown 1.0.0 -> ",
+		"jx-old":     "upgrade 1.0.0 -> 2.0.0",
+	}, actions)
+
+	drifts, err = installer.Drift(dir, managed, false)
+	require.NoError(t, err)
+	assert.Len(t, drifts, 3, "should only include the managed plugins")
+}
@@ -0,0 +1,118 @@
+package plugins
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+)
+
+// AlwaysPassEnv the patterns of the environment variables which are passed to every plugin
+var AlwaysPassEnv = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "COLORTERM", "NO_COLOR", "LANG", "LC_*", "TZ", "TMPDIR",
+	"KUBECONFIG", "XDG_*", "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"JX3_HOME", "JX_OFFLINE", "BINARY_NAME", "TOP_LEVEL_COMMAND",
+	// needed to run processes on windows
+	"SYSTEMROOT", "SYSTEMDRIVE", "USERPROFILE", "APPDATA", "LOCALAPPDATA", "PATHEXT", "COMSPEC", "TEMP", "TMP",
+}
+
+// EnvPolicy returns the policy controlling the environment variables passed to the given plugin binary
+func EnvPolicy(env *config.Env, binaryName string, managed bool) *config.EnvPolicy {
+	if env != nil {
+		if p := env.Plugins[binaryName]; p != nil {
+			return p
+		}
+		if p := env.Plugins[strings.TrimPrefix(binaryName, "jx-")]; p != nil {
+			return p
+		}
+		if managed && env.Managed != nil {
+			return env.Managed
+		}
+		if !managed && env.Unmanaged != nil {
+			return env.Unmanaged
+		}
+	}
+	return &config.EnvPolicy{PassAll: managed}
+}
+
+// FilterEnv returns the environment variables of the KEY=VALUE environment which the plugin is allowed to see
+func FilterEnv(env *config.Env, binaryName string, managed bool, environment []string) []string {
+	policy := EnvPolicy(env, binaryName, managed)
+	alwaysPass := AlwaysPassEnv
+	if env != nil {
+		alwaysPass = append(append([]string{}, AlwaysPassEnv...), env.AlwaysPass...)
+	}
+
+	var answer, removed []string
+	for _, kv := range environment {
+		name, _, _ := strings.Cut(kv, "=")
+		if matchesEnv(alwaysPass, name) || (!matchesEnv(policy.Deny, name) && (policy.PassAll || matchesEnv(policy.Allow, name))) {
+			answer = append(answer, kv)
+		} else {
+			removed = append(removed, name)
+		}
+	}
+	if len(removed) > 0 {
+		log.Logger().Debugf("not passing environment variables to plugin %s: %s", binaryName, strings.Join(removed, ", "))
+	}
+	return answer
+}
+
+// PluginEnv returns the environment variables of the KEY=VALUE environment which the plugin binary at the given path
+// is allowed to see when jx invokes it for its metadata or completions.
+//
+// Only the versions of the managed plugins installed by jx, whose file names end with their version, are given the
+// managed policy
+func PluginEnv(env *config.Env, binaryPath string, environment []string) []string {
+	binaryName := strings.TrimSuffix(filepath.Base(binaryPath), ".exe")
+	managed := false
+	if m := installedPluginPattern.FindStringSubmatch(binaryName); m != nil {
+		binaryName = m[1]
+		managed = PluginMap[binaryName] != nil
+	}
+	return FilterEnv(env, binaryName, managed, environment)
+}
+
+// loadEnv returns the plugin environment policies of the jx configuration
+func loadEnv() (*config.Env, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jx configuration: %w", err)
+	}
+	return &cfg.Env, nil
+}
+
+// IsJenkinsXPlugin returns true if the plugin is released by the jenkins-x-plugins organisation
+func IsJenkinsXPlugin(plugin *jenkinsv1.Plugin) bool {
+	if plugin == nil || len(plugin.Spec.Binaries) == 0 {
+		return false
+	}
+	for _, b := range plugin.Spec.Binaries {
+		if !strings.HasPrefix(b.URL, "https://github.com/"+jenkinsxPluginsOrganisation+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesEnv returns true if the environment variable name matches any of the patterns
+func matchesEnv(patterns []string, name string) bool {
+	if runtime.GOOS == "windows" {
+		// environment variable names are case insensitive on windows
+		name = strings.ToUpper(name)
+	}
+	for _, p := range patterns {
+		if runtime.GOOS == "windows" {
+			p = strings.ToUpper(p)
+		}
+		if ok, err := path.Match(p, name); ok && err == nil {
+			return true
+		}
+	}
+	return false
+}
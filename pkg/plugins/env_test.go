@@ -0,0 +1,99 @@
+package plugins_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterEnv(t *testing.T) {
+	t.Parallel()
+
+	environment := []string{
+		"PATH=/usr/bin",
+		"HOME=/home/bob",
+		"KUBECONFIG=/home/bob/.kube/config",
+		"LC_ALL=C",
+		"AWS_SECRET_ACCESS_KEY=abc",
+		"AWS_REGION=us-east-1",
+		"GIT_TOKEN=def",
+	}
+
+	testCases := []struct {
+		name     string
+		env      *config.Env
+		binary   string
+		managed  bool
+		expected []string
+	}{
+		{
+			name:     "managed plugins see everything by default",
+			binary:   "jx-gitops",
+			managed:  true,
+			expected: environment,
+		},
+		{
+			name:     "unmanaged plugins only see the always pass set by default",
+			binary:   "jx-acme",
+			expected: []string{"PATH=/usr/bin", "HOME=/home/bob", "KUBECONFIG=/home/bob/.kube/config", "LC_ALL=C"},
+		},
+		{
+			name: "unmanaged plugin allowlist",
+			env: &config.Env{
+				Plugins: map[string]*config.EnvPolicy{
+					"acme": {Allow: []string{"AWS_*"}, Deny: []string{"*SECRET*"}},
+				},
+			},
+			binary:   "jx-acme",
+			expected: []string{"PATH=/usr/bin", "HOME=/home/bob", "KUBECONFIG=/home/bob/.kube/config", "LC_ALL=C", "AWS_REGION=us-east-1"},
+		},
+		{
+			name: "managed plugin denylist",
+			env: &config.Env{
+				Managed: &config.EnvPolicy{PassAll: true, Deny: []string{"AWS_*", "PATH"}},
+			},
+			binary:   "jx-gitops",
+			managed:  true,
+			expected: []string{"PATH=/usr/bin", "HOME=/home/bob", "KUBECONFIG=/home/bob/.kube/config", "LC_ALL=C", "GIT_TOKEN=def"},
+		},
+		{
+			name: "additional always pass patterns",
+			env: &config.Env{
+				AlwaysPass: []string{"GIT_TOKEN"},
+			},
+			binary:   "jx-acme",
+			expected: []string{"PATH=/usr/bin", "HOME=/home/bob", "KUBECONFIG=/home/bob/.kube/config", "LC_ALL=C", "GIT_TOKEN=def"},
+		},
+	}
+
+	for _, tc := range testCases {
+		got := plugins.FilterEnv(tc.env, tc.binary, tc.managed, environment)
+		assert.Equal(t, tc.expected, got, tc.name)
+	}
+}
+
+func TestPluginEnv(t *testing.T) {
+	t.Parallel()
+
+	environment := []string{"PATH=/usr/bin", "GITHUB_TOKEN=secret"}
+	managed := "/home/me/.jx/plugins/bin/jx-gitops-" + plugins.GitOpsVersion
+	assert.Equal(t, environment, plugins.PluginEnv(nil, managed, environment), "should pass everything to managed plugins")
+
+	for _, path := range []string{"/home/me/.jx/plugins/bin/jx-mytool-1.0.0", "/usr/local/bin/jx-gitops"} {
+		assert.Equal(t, []string{"PATH=/usr/bin"}, plugins.PluginEnv(nil, path, environment), "for %s", path)
+	}
+}
+
+func TestIsJenkinsXPlugin(t *testing.T) {
+	t.Parallel()
+
+	p := extensions.CreateJXPlugin("jenkins-x-plugins", "gitops", "1.2.3")
+	assert.True(t, plugins.IsJenkinsXPlugin(&p))
+
+	p = extensions.CreateJXPlugin("acme", "gitops", "1.2.3")
+	assert.False(t, plugins.IsJenkinsXPlugin(&p))
+	assert.False(t, plugins.IsJenkinsXPlugin(nil))
+}
@@ -0,0 +1,134 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+// signalExitCodeOffset the offset added to the signal number to create the exit code of a process killed by a signal
+const signalExitCodeOffset = 128
+
+// Executor invokes a plugin binary
+type Executor interface {
+	// Execute runs the binary with the given arguments and KEY=VALUE environment returning its exit code.
+	//
+	// An error is returned if the binary could not be started
+	Execute(binary string, args, environment []string) (int, error)
+}
+
+// ExecExecutor replaces the current process with the plugin so that it only returns if the plugin could not be started.
+//
+// Windows does not support replacing the current process so the plugin is run as a child process instead
+type ExecExecutor struct{}
+
+// ChildExecutor runs the plugin as a child process forwarding interrupt and terminate signals to it.
+//
+// Interrupts are not forwarded while the plugin is in the foreground process group of the terminal as the
+// terminal sends them to the plugin too
+type ChildExecutor struct {
+	// Stdin the standard input of the plugin. Defaults to os.Stdin
+	Stdin io.Reader
+
+	// Stdout the standard output of the plugin. Defaults to os.Stdout
+	Stdout io.Writer
+
+	// Stderr the standard error of the plugin. Defaults to os.Stderr
+	Stderr io.Writer
+}
+
+// NewExecutor returns the executor used to invoke plugins. A child process is only needed if anything has to run
+// after the plugin completes such as post hooks or timing the plugin for the history
+func NewExecutor(child bool) Executor {
+	if child {
+		return &ChildExecutor{}
+	}
+	return &ExecExecutor{}
+}
+
+// Execute replaces the current process with the plugin
+func (e *ExecExecutor) Execute(binary string, args, environment []string) (int, error) {
+	if runtime.GOOS == "windows" {
+		return (&ChildExecutor{}).Execute(binary, args, environment)
+	}
+
+	// invoke cmd binary relaying the environment and args given
+	// append binary to args, as execve will make first argument the "binary name".
+	err := syscall.Exec(binary, append([]string{binary}, args...), environment) //nolint:gosec
+	return 0, fmt.Errorf("failed to execute plugin %s: %w", binary, err)
+}
+
+// Execute runs the plugin as a child process and returns its exit code
+func (e *ChildExecutor) Execute(binary string, args, environment []string) (int, error) {
+	cmd := exec.Command(binary, args...)
+	cmd.Stdin = e.Stdin
+	cmd.Stdout = e.Stdout
+	cmd.Stderr = e.Stderr
+	if cmd.Stdin == nil {
+		cmd.Stdin = os.Stdin
+	}
+	if cmd.Stdout == nil {
+		cmd.Stdout = os.Stdout
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	cmd.Env = environment
+
+	// lets make sure we outlive the plugin so that we can propagate its exit code
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	err := cmd.Start()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run plugin %s: %w", binary, err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				if sig == os.Interrupt && inForeground(cmd.Process.Pid) {
+					// the plugin already received the interrupt from the terminal and a second one would
+					// often force it to quit
+					continue
+				}
+				// the process may have already exited or the signal may not be supported on this platform
+				_ = cmd.Process.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	code, err := exitCode(cmd.Wait())
+	if err != nil {
+		return 0, fmt.Errorf("failed to wait for plugin %s: %w", binary, err)
+	}
+	return code, nil
+}
+
+// exitCode returns the exit code of a process from the error returned when waiting for it to complete.
+//
+// Processes killed by a signal return 128 plus the signal number like shells do
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0, err
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return signalExitCodeOffset + int(status.Signal()), nil
+	}
+	return exitErr.ExitCode(), nil
+}
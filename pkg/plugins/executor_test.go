@@ -0,0 +1,79 @@
+package plugins_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChildExecutor(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "jx-foo")
+	writeScript(t, binary, "echo \"$* $FOO\"\nexit 42\n")
+
+	out := &bytes.Buffer{}
+	executor := &plugins.ChildExecutor{Stdout: out}
+	exitCode, err := executor.Execute(binary, []string{"bar"}, []string{"FOO=thingy"})
+	require.NoError(t, err)
+	assert.Equal(t, 42, exitCode)
+	assert.Equal(t, "bar thingy\n", out.String())
+
+	_, err = executor.Execute(filepath.Join(dir, "does-not-exist"), nil, nil)
+	assert.Error(t, err)
+}
+
+func TestChildExecutorForwardsSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts and signals")
+	}
+	if tty, err := os.Open("/dev/tty"); err == nil {
+		tty.Close()
+		t.Skip("the terminal sends interrupts to the plugin when it is in the foreground")
+	}
+
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "jx-foo")
+	writeScript(t, binary, "n=0\ntrap 'n=$((n+1))' INT\ntrap 'exit $((7+n))' TERM\necho started\nwhile true; do sleep 0.1; done\n")
+
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+	defer r.Close()
+
+	done := make(chan int, 1)
+	go func() {
+		exitCode, _ := (&plugins.ChildExecutor{Stdout: w}).Execute(binary, nil, os.Environ())
+		w.Close()
+		done <- exitCode
+	}()
+
+	// lets wait for the plugin to start before signalling ourselves
+	buf := make([]byte, len("started"))
+	_, err = r.Read(buf)
+	require.NoError(t, err)
+	// without a terminal to send the plugin the interrupt it should be forwarded
+	err = syscall.Kill(os.Getpid(), syscall.SIGINT)
+	require.NoError(t, err)
+	time.Sleep(200 * time.Millisecond)
+	err = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	require.NoError(t, err)
+
+	select {
+	case exitCode := <-done:
+		assert.Equal(t, 8, exitCode, "should propagate the exit code of the plugin after forwarding the interrupt")
+	case <-time.After(10 * time.Second):
+		t.Fatal("the signal was not forwarded to the plugin")
+	}
+}
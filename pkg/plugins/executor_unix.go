@@ -0,0 +1,28 @@
+//go:build !windows
+
+package plugins
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// inForeground returns true if the process is in the foreground process group of the controlling terminal
+// so that the terminal sends it the interrupts itself
+func inForeground(pid int) bool {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return false
+	}
+	defer tty.Close()
+	foreground, err := unix.IoctlGetInt(int(tty.Fd()), unix.TIOCGPGRP)
+	if err != nil {
+		return false
+	}
+	pgid, err := unix.Getpgid(pid)
+	if err != nil {
+		return false
+	}
+	return pgid == foreground
+}
@@ -0,0 +1,8 @@
+//go:build windows
+
+package plugins
+
+// inForeground returns true as the console sends interrupts to every process attached to it
+func inForeground(_ int) bool {
+	return true
+}
@@ -1,74 +1,45 @@
 package plugins
 
 import (
-	"bytes"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
-	"runtime"
 	"sort"
-	"strconv"
 	"strings"
-	"syscall"
 
-	"github.com/blang/semver"
 	"github.com/spf13/cobra"
 
 	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
 	"github.com/jenkins-x/jx-helpers/v3/pkg/homedir"
-	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
-	"k8s.io/apimachinery/pkg/util/json"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
 )
 
 const (
 	jenkinsxPluginsOrganisation = "jenkins-x-plugins"
 )
 
-type githubRelease struct {
-	TagName string `json:"tag_name"`
-}
-
 // InstallStandardPlugin makes sure that latest version of plugin is installed and returns the path to the binary
 func InstallStandardPlugin(dir, name string) (string, error) {
-	u := "https://api.github.com/repos/jenkins-x-plugins/" + name + "/releases/latest"
-
-	client := httphelpers.GetClient()
-	req, err := http.NewRequest("GET", u, http.NoBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to create http request for %s: %w", u, err)
-	}
-	resp, err := client.Do(req)
-	if err != nil {
-		if resp != nil {
-			return "", fmt.Errorf("failed to GET endpoint %s with status %s: %w", u, resp.Status, err)
-		}
-		return "", fmt.Errorf("failed to GET endpoint %s: %w", u, err)
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	installer, err := NewInstaller()
 	if err != nil {
-		return "", fmt.Errorf("failed to read response from %s: %w", u, err)
+		return "", err
 	}
+	return installer.InstallStandardPlugin(dir, name)
+}
 
-	release := &githubRelease{}
-	err = json.Unmarshal(body, release)
-	if err != nil {
-		return "", fmt.Errorf("failed to unmarshal release from %s: %w", u, err)
-	}
-	latestVersion := strings.TrimPrefix(release.TagName, "v")
-	if latestVersion == "" {
-		return "", fmt.Errorf("can't find latest version of plugin: %s", body)
+// InstallStandardPlugin makes sure that latest version of plugin is installed and returns the path to the binary.
+//
+// Plugins installed from a repository are installed from the latest release of that repository
+func (i *Installer) InstallStandardPlugin(dir, name string) (string, error) {
+	if src := i.Sources.Find(BinaryName(name)); src != nil {
+		return i.InstallFromSource(dir, src, "")
 	}
-
-	plugin := extensions.CreateJXPlugin("jenkins-x-plugins", strings.TrimPrefix(name, "jx-"), latestVersion)
+	tagName, err := i.LatestRelease(jenkinsxPluginsOrganisation, name)
 	if err != nil {
 		return "", err
 	}
-	return extensions.EnsurePluginInstalled(plugin, dir)
+	latestVersion := strings.TrimPrefix(tagName, "v")
+
+	plugin := extensions.CreateJXPlugin(jenkinsxPluginsOrganisation, strings.TrimPrefix(name, "jx-"), latestVersion)
+	return i.EnsurePluginInstalled(plugin, dir)
 }
 
 // AllPlugins lists all plugins
@@ -80,23 +51,27 @@ func AllPlugins() (validArgs []string) {
 	if err != nil {
 		return
 	}
-	file, err := os.Open(pluginBinDir)
+	installed, err := InstalledPlugins(pluginBinDir)
 	if err != nil {
 		return
 	}
-	defer file.Close()
-	files, err := file.Readdirnames(0)
-	if err != nil {
-		return
+	// plugins installed from repositories are included even if they have been removed as they are reinstalled on use
+	sources, err := LoadSources()
+	if err == nil {
+		for _, binaryName := range sources.Names() {
+			if _, ok := installed[binaryName]; !ok {
+				installed[binaryName] = nil
+			}
+		}
 	}
-	pluginPattern := regexp.MustCompile("^jx-(.*)-[0-9.]+$")
-	for _, plugin := range files {
-		res := pluginPattern.FindStringSubmatch(plugin)
-		if len(res) > 1 && PluginMap["jx-"+res[1]] == nil {
-			validArgs = append(validArgs, res[1])
+	var names []string
+	for binaryName := range installed {
+		if PluginMap[binaryName] == nil {
+			names = append(names, strings.TrimPrefix(binaryName, "jx-"))
 		}
 	}
-	return
+	sort.Strings(names)
+	return append(validArgs, names...)
 }
 
 // SetupPluginCompletion adds a Cobra command to the command tree for each
@@ -113,14 +88,24 @@ func SetupPluginCompletion(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	RegisterPluginCommands(root, true)
+	// completion must be fast and never use the network so lets only use the cached plugin metadata
+	RegisterCachedPluginCommands(root)
 }
 
 // RegisterPluginCommands allows adding Cobra command to the command tree or extracting them for usage in
-// e.g. the help function or for registering the completion function
+// e.g. the help function or for registering the completion function.
+//
+// Installed plugins which support the metadata protocol are described using their Metadata
 func RegisterPluginCommands(rootCmd *cobra.Command, list bool) (cmds []*cobra.Command) {
+	return registerPluginCommands(rootCmd, list, NewMetadataLoader())
+}
+
+func registerPluginCommands(rootCmd *cobra.Command, list bool, loader *MetadataLoader) []*cobra.Command {
 	var userDefinedCommands []*cobra.Command
 
+	pluginBinDir, _ := homedir.DefaultPluginBinDir()
+	installed, _ := InstalledPlugins(pluginBinDir)
+
 	for _, plugin := range AllPlugins() {
 		var args []string
 
@@ -145,8 +130,9 @@ func RegisterPluginCommands(rootCmd *cobra.Command, list bool) (cmds []*cobra.Co
 			parentCmd = rootCmd
 		}
 
+		var cmd *cobra.Command
 		for _, remainingArg := range remainingArgs {
-			cmd := &cobra.Command{
+			cmd = &cobra.Command{
 				Use: remainingArg,
 				// Add a description that will be shown with completion choices.
 				// Make each one different by including the plugin name to avoid
@@ -166,6 +152,23 @@ func RegisterPluginCommands(rootCmd *cobra.Command, list bool) (cmds []*cobra.Co
 				parentCmd = cmd
 			}
 		}
+
+		// lets describe the command of the whole plugin using its metadata if it is installed
+		if cmd != nil && len(args) == len(rawPluginArgs) {
+			binaryName := "jx-" + plugin
+			path := newPluginInfo(pluginBinDir, binaryName, installed[binaryName]).Path
+			if path == "" {
+				continue
+			}
+			m, err := loader.Load(path)
+			if err != nil {
+				log.Logger().Debugf("failed to load metadata of plugin %s: %s", binaryName, err.Error())
+				continue
+			}
+			if m != nil {
+				applyMetadata(cmd, m, path, nil)
+			}
+		}
 	}
 
 	return userDefinedCommands
@@ -196,6 +199,8 @@ func RegisterPluginCommands(rootCmd *cobra.Command, list bool) (cmds []*cobra.Co
 // directives to the shell on how to perform completion.  If this directive is not present, the
 // cobra.ShellCompDirectiveDefault will be used. Please see Cobra's documentation for more details:
 // https://github.com/spf13/cobra/blob/master/shell_completions.md#dynamic-completion-of-nouns
+//
+// Plugins which are not installed are never installed during completion, there are just no completion choices
 func PluginCompletion(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 	// Recreate the plugin name from the commandPath
 	pluginName := strings.ReplaceAll(strings.ReplaceAll(cmd.CommandPath(), "-", "_"), " ", "-")
@@ -204,131 +209,55 @@ func PluginCompletion(cmd *cobra.Command, args []string, toComplete string) ([]s
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
-	path, err := Lookup(pluginName, pluginDir)
+	path, err := LookupInstalled(pluginName, pluginDir)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
 
-	newArgs := make([]string, len(args)+2) //nolint:mnd
-	newArgs[0] = "__complete"
-	for i, arg := range args {
-		newArgs[i+1] = arg
-	}
-	newArgs[len(newArgs)-1] = toComplete
-	cobra.CompDebugln(fmt.Sprintf("About to call: %s %s", path, strings.Join(args, " ")), true)
-	return getPluginCompletions(path, newArgs, os.Environ())
+	return pluginCompletion(path, args, toComplete)
 }
 
-// getPluginCompletions receives an executable's filepath, a slice
-// of arguments, and a slice of environment variables
-// to relay to the executable.
-func getPluginCompletions(executablePath string, cmdArgs, environment []string) ([]string, cobra.ShellCompDirective) {
-	buf := new(bytes.Buffer)
-
-	prog := exec.Command(executablePath, cmdArgs...)
-	prog.Stdin = os.Stdin
-	prog.Stdout = buf
-	prog.Stderr = os.Stderr
-	prog.Env = environment
-
-	var comps []string
-	directive := cobra.ShellCompDirectiveNoFileComp
-	if err := prog.Run(); err == nil {
-		for _, comp := range strings.Split(buf.String(), "\n") {
-			// Remove any empty lines
-			if comp != "" {
-				comps = append(comps, comp)
-			}
-		}
-
-		// Check if the last line of output is of the form :<integer>, which
-		// indicates a Cobra ShellCompDirective.  We do this for plugins
-		// that use Cobra or the ones that wish to use this directive to
-		// communicate a special behavior for the shell.
-		if len(comps) > 0 {
-			lastLine := comps[len(comps)-1]
-			if len(lastLine) > 1 && lastLine[0] == ':' {
-				if strInt, err := strconv.Atoi(lastLine[1:]); err == nil {
-					directive = cobra.ShellCompDirective(strInt)
-					comps = comps[:len(comps)-1]
-				}
-			}
-		}
-	}
-	return comps, directive
+// pluginCompletion calls the plugin binary to complete the given arguments
+func pluginCompletion(path string, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return NewPluginCompleter().Complete(path, args, toComplete)
 }
 
-// FindStandardPlugin finds standard plugin
+// FindStandardPlugin returns the newest installed version of the plugin in the dir installing it only if it is
+// a known plugin
 func FindStandardPlugin(dir, name string) (string, error) {
-	file, err := os.Open(dir)
-	if err != nil {
-		return "", fmt.Errorf("failed to read plugin dir %s: %w", dir, err)
-	}
-	defer file.Close()
-	files, err := file.Readdirnames(0)
-	if err != nil {
-		return "", fmt.Errorf("failed to read plugin dir %s: %w", dir, err)
-	}
-	pluginPattern, err := regexp.Compile("^" + name + "-([0-9.]+)$")
+	versions, err := InstalledVersions(dir, name)
 	if err != nil {
 		return "", err
 	}
-
-	vers := make([]string, 0)
-	for _, plugin := range files {
-		res := pluginPattern.FindStringSubmatch(plugin)
-		if len(res) > 1 {
-			vers = append(vers, res[1])
-		}
+	if len(versions) > 0 {
+		return PluginBinary(dir, name, versions[0]), nil
 	}
+	return NewResolver(NewSearchPath(dir)).Install(name)
+}
 
-	if len(vers) > 0 {
-		vs := make([]semver.Version, 0)
-		for _, r := range vers {
-			v, err := semver.Parse(r)
-			if err == nil {
-				vs = append(vs, v)
-			}
-		}
-
-		sort.Sort(sort.Reverse(semver.Versions(vs)))
-		if len(vs) > 0 {
-			return filepath.Join(dir, name+"-"+vs[0].String()), nil
+// LookupInstalled looks up the given plugin binary in the search path without installing it, preferring the
+// pinned version of managed plugins
+func LookupInstalled(filename, pluginBinDir string) (string, error) {
+	searchPath := NewSearchPath(pluginBinDir)
+	if p := PluginMap[filename]; p != nil {
+		if l := searchPath.FindVersion(filename, p.Spec.Version); l != nil {
+			return l.Path, nil
 		}
 	}
-	return InstallStandardPlugin(dir, name)
+	if l := searchPath.Find(filename); l != nil {
+		return l.Path, nil
+	}
+	return "", fmt.Errorf("plugin %s is not installed", filename)
 }
 
-// Lookup looks up the given file
+// Lookup looks up the given plugin binary in the search path installing it only if it is a known plugin
 func Lookup(filename, pluginBinDir string) (string, error) {
-	path, err := exec.LookPath(filename)
+	path, err := NewResolver(NewSearchPath(pluginBinDir)).Resolve(filename)
 	if err != nil {
-		path, err = FindStandardPlugin(pluginBinDir, filename)
-		if err != nil {
-			return "", fmt.Errorf("failed to load plugin %s: %w", filename, err)
+		if IsUnknownPluginError(err) {
+			return "", err
 		}
+		return "", fmt.Errorf("failed to load plugin %s: %w", filename, err)
 	}
 	return path, nil
 }
-
-// Execute performs the command
-func Execute(executablePath string, cmdArgs, environment []string) error {
-	// Windows does not support exec syscall.
-	if runtime.GOOS == "windows" {
-		cmd := exec.Command(executablePath, cmdArgs...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Stdin = os.Stdin
-		cmd.Env = environment
-		err := cmd.Run()
-		if err == nil {
-			os.Exit(0)
-		}
-		return err
-	}
-
-	// invoke cmd binary relaying the environment and args given
-	// append executablePath to cmdArgs, as execve will make first argument the "binary name".
-	// ToDo: Look at sanitizing the inputs passed to syscall exec, may be move away from syscall as it's deprecated.
-	return syscall.Exec(executablePath, append([]string{executablePath}, cmdArgs...), environment) //nolint
-}
@@ -0,0 +1,213 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+)
+
+const (
+	// HooksDirName the name of the directory in the jx home dir containing hook executables.
+	// Executables with the pre- prefix are run before a plugin and those with the post- prefix afterwards
+	HooksDirName = "hooks"
+
+	// HookEnvVar the environment variable containing the hook phase: pre or post
+	HookEnvVar = "JX_HOOK"
+
+	// HookPluginNameEnvVar the environment variable containing the binary name of the plugin such as jx-gitops
+	HookPluginNameEnvVar = "JX_HOOK_PLUGIN_NAME"
+
+	// HookPluginVersionEnvVar the environment variable containing the version of the plugin if known
+	HookPluginVersionEnvVar = "JX_HOOK_PLUGIN_VERSION"
+
+	// HookPluginBinaryEnvVar the environment variable containing the path of the plugin binary
+	HookPluginBinaryEnvVar = "JX_HOOK_PLUGIN_BINARY"
+
+	// HookPluginArgsEnvVar the environment variable containing the JSON array of the plugin arguments
+	HookPluginArgsEnvVar = "JX_HOOK_PLUGIN_ARGS"
+
+	// HookExitCodeEnvVar the environment variable containing the exit code of the plugin in post hooks
+	HookExitCodeEnvVar = "JX_HOOK_EXIT_CODE"
+
+	preHookPrefix  = "pre-"
+	postHookPrefix = "post-"
+
+	executableFileMode os.FileMode = 0o111
+)
+
+// Invocation describes a resolved plugin invocation
+type Invocation struct {
+	// Name the binary name of the plugin such as jx-gitops
+	Name string
+
+	// Version the version of the plugin or empty if it is not known
+	Version string
+
+	// Binary the path of the plugin binary
+	Binary string
+
+	// Args the arguments passed to the plugin
+	Args []string
+
+	// Env the filtered environment of the plugin or nil to use the environment the hooks are run with
+	Env []string
+}
+
+// NewInvocation creates an invocation of the given plugin binary
+func NewInvocation(name, binary string, args []string) *Invocation {
+	inv := &Invocation{
+		Name:   name,
+		Binary: binary,
+		Args:   args,
+	}
+	res := installedPluginPattern.FindStringSubmatch(filepath.Base(binary))
+	if len(res) > 2 && res[1] == name {
+		inv.Version = res[2]
+	}
+	return inv
+}
+
+// Hooks the executables run before and after plugins are invoked
+type Hooks struct {
+	// Pre the executables run before a plugin. If any fail the plugin is not invoked
+	Pre []string
+
+	// Post the executables run after a plugin has completed
+	Post []string
+
+	// Executor invokes the plugin. Defaults to running it as a child process so that the post hooks can run
+	Executor Executor
+}
+
+// LoadHooks loads the hooks from the hooks dir in the jx home dir and the given configuration
+func LoadHooks(cfg *config.Config) (*Hooks, error) {
+	dir, err := config.HomeDir()
+	if err != nil {
+		return nil, err
+	}
+	h, err := LoadHooksDir(filepath.Join(dir, HooksDirName))
+	if err != nil {
+		return nil, err
+	}
+	h.Pre = append(h.Pre, cfg.Hooks.Pre...)
+	h.Post = append(h.Post, cfg.Hooks.Post...)
+	return h, nil
+}
+
+// LoadHooksDir loads the pre- and post- prefixed executables in the given directory sorted by name
+func LoadHooksDir(dir string) (*Hooks, error) {
+	h := &Hooks{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return h, nil
+		}
+		return nil, fmt.Errorf("failed to read hooks dir %s: %w", dir, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat hook %s: %w", e.Name(), err)
+		}
+		if runtime.GOOS != "windows" && fi.Mode()&executableFileMode == 0 {
+			log.Logger().Debugf("ignoring hook %s as it is not executable", e.Name())
+			continue
+		}
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		switch {
+		case strings.HasPrefix(name, preHookPrefix):
+			h.Pre = append(h.Pre, path)
+		case strings.HasPrefix(name, postHookPrefix):
+			h.Post = append(h.Post, path)
+		}
+	}
+	return h, nil
+}
+
+// IsEmpty returns true if there are no hooks
+func (h *Hooks) IsEmpty() bool {
+	return h == nil || (len(h.Pre) == 0 && len(h.Post) == 0)
+}
+
+// HasPost returns true if there are post hooks which need the plugin to run as a child process
+func (h *Hooks) HasPost() bool {
+	return h != nil && len(h.Post) > 0
+}
+
+// Run runs the pre hooks, the plugin using the executor and then the post hooks returning the exit code of the plugin.
+//
+// An error is returned if a pre hook fails or the plugin could not be started
+func (h *Hooks) Run(inv *Invocation, environment []string) (int, error) {
+	for _, hook := range h.Pre {
+		err := runHook(hook, "pre", inv, environment, -1)
+		if err != nil {
+			return 0, fmt.Errorf("pre hook %s failed so not invoking plugin %s: %w", hook, inv.Name, err)
+		}
+	}
+
+	pluginEnv := environment
+	if inv.Env != nil {
+		pluginEnv = inv.Env
+	}
+	executor := h.Executor
+	if executor == nil {
+		executor = &ChildExecutor{}
+	}
+	exitCode, err := executor.Execute(inv.Binary, inv.Args, pluginEnv)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, hook := range h.Post {
+		err := runHook(hook, "post", inv, environment, exitCode)
+		if err != nil {
+			log.Logger().Warnf("post hook %s failed: %s", hook, err.Error())
+		}
+	}
+	return exitCode, nil
+}
+
+// runHook runs the hook passing the details of the invocation as environment variables and the plugin
+// arguments as arguments
+func runHook(hook, phase string, inv *Invocation, environment []string, exitCode int) error {
+	args, err := json.Marshal(inv.Args)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin arguments: %w", err)
+	}
+	env := append(append([]string{}, environment...),
+		HookEnvVar+"="+phase,
+		HookPluginNameEnvVar+"="+inv.Name,
+		HookPluginVersionEnvVar+"="+inv.Version,
+		HookPluginBinaryEnvVar+"="+inv.Binary,
+		HookPluginArgsEnvVar+"="+string(args),
+	)
+	if exitCode >= 0 {
+		env = append(env, HookExitCodeEnvVar+"="+strconv.Itoa(exitCode))
+	}
+
+	cmd := exec.Command(hook, inv.Args...)
+	cmd.Env = env
+	cmd.Stdin = os.Stdin
+	// lets keep the stdout of the plugin clean for anything parsing it
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	log.Logger().Debugf("running %s hook %s", phase, hook)
+	return cmd.Run()
+}
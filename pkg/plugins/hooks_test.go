@@ -0,0 +1,84 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, path, script string) {
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0o700) //nolint:gosec
+	require.NoError(t, err)
+}
+
+func TestLoadHooksDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeScript(t, filepath.Join(dir, "pre-20-refresh"), "")
+	writeScript(t, filepath.Join(dir, "pre-10-audit"), "")
+	writeScript(t, filepath.Join(dir, "post-audit"), "")
+	writeScript(t, filepath.Join(dir, "README"), "")
+	err := os.WriteFile(filepath.Join(dir, "pre-disabled"), []byte("#!/bin/sh\n"), 0o600)
+	require.NoError(t, err)
+
+	hooks, err := plugins.LoadHooksDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "pre-10-audit"), filepath.Join(dir, "pre-20-refresh")}, hooks.Pre)
+	assert.Equal(t, []string{filepath.Join(dir, "post-audit")}, hooks.Post)
+	assert.True(t, hooks.HasPost())
+
+	hooks, err = plugins.LoadHooksDir(filepath.Join(dir, "does-not-exist"))
+	require.NoError(t, err)
+	assert.True(t, hooks.IsEmpty())
+	assert.False(t, hooks.HasPost())
+}
+
+func TestHooksRun(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "audit.log")
+	binary := plugins.PluginBinary(dir, "jx-foo", "1.2.3")
+	writeScript(t, binary, "echo \"plugin $*\" >> "+logFile+"\nexit 3\n")
+	pre := filepath.Join(dir, "pre-audit")
+	writeScript(t, pre, "echo \"pre $JX_HOOK $JX_HOOK_PLUGIN_NAME $JX_HOOK_PLUGIN_VERSION $JX_HOOK_PLUGIN_ARGS\" >> "+logFile+"\n")
+	post := filepath.Join(dir, "post-audit")
+	writeScript(t, post, "echo \"post $JX_HOOK $JX_HOOK_EXIT_CODE $*\" >> "+logFile+"\n")
+
+	hooks := &plugins.Hooks{Pre: []string{pre}, Post: []string{post}}
+	inv := plugins.NewInvocation("jx-foo", binary, []string{"bar", "--name", "thingy"})
+	exitCode, err := hooks.Run(inv, os.Environ())
+	require.NoError(t, err)
+	assert.Equal(t, 3, exitCode)
+
+	data, err := os.ReadFile(logFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`pre pre jx-foo 1.2.3 ["bar","--name","thingy"]`,
+		"plugin bar --name thingy",
+		"post post 3 bar --name thingy",
+	}, strings.Split(strings.TrimSpace(string(data)), "\n"))
+}
+
+func TestHooksRunFailingPreHook(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "audit.log")
+	binary := filepath.Join(dir, "jx-foo")
+	writeScript(t, binary, "echo plugin >> "+logFile+"\n")
+	pre := filepath.Join(dir, "pre-policy")
+	writeScript(t, pre, "exit 1\n")
+
+	hooks := &plugins.Hooks{Pre: []string{pre}}
+	_, err := hooks.Run(plugins.NewInvocation("jx-foo", binary, nil), os.Environ())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not invoking plugin jx-foo")
+	assert.NoFileExists(t, logFile, "the plugin should not have been invoked")
+}
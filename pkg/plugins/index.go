@@ -0,0 +1,420 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ManagedIndexName the name of the built in index containing the managed plugins of this version of jx
+	ManagedIndexName = "jx"
+
+	// IndexCacheTTLEnvVar the environment variable used to override how long plugin indexes are cached for
+	IndexCacheTTLEnvVar = "JX_INDEX_CACHE_TTL"
+
+	// DefaultIndexCacheTTL how long a plugin index is cached before it is fetched again
+	DefaultIndexCacheTTL = time.Hour
+
+	// indexCacheDirName the directory in the cache dir plugin indexes are cached in
+	indexCacheDirName = "indexes"
+)
+
+var yamlDocumentSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+
+// IndexPlugin a plugin found in a plugin index
+type IndexPlugin struct {
+	// Index the name of the index containing the plugin
+	Index string `json:"index"`
+
+	// Plugin the plugin spec
+	Plugin jenkinsv1.Plugin `json:"plugin"`
+}
+
+// IndexLoader loads the plugins of the configured plugin indexes, caching them in the cache dir
+type IndexLoader struct {
+	// Indexes the configured plugin indexes
+	Indexes []config.PluginIndex
+
+	// CacheDir the directory the indexes are cached in
+	CacheDir string
+
+	// CacheTTL how long an index is cached before it is fetched again
+	CacheTTL time.Duration
+
+	// Refresh fetches the indexes even if the cache has not expired
+	Refresh bool
+
+	// CacheOnly only reads the cached copies of the indexes without using the network such as when suggesting commands
+	CacheOnly bool
+
+	// Client the HTTP client used to download indexes
+	Client *http.Client
+
+	// CommandRunner the runner of the git commands used to fetch git indexes
+	CommandRunner cmdrunner.CommandRunner
+}
+
+// NewIndexLoader creates a loader of the plugin indexes in the jx configuration
+func NewIndexLoader() (*IndexLoader, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jx configuration: %w", err)
+	}
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	return &IndexLoader{
+		Indexes:       cfg.PluginIndexes,
+		CacheDir:      cacheDir,
+		CacheTTL:      IndexCacheTTL(),
+		Client:        httphelpers.GetClient(),
+		CommandRunner: cmdrunner.QuietCommandRunner,
+	}, nil
+}
+
+// IndexCacheTTL returns how long plugin indexes are cached for
+func IndexCacheTTL() time.Duration {
+	v := os.Getenv(IndexCacheTTLEnvVar)
+	if v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil {
+			return d
+		}
+		log.Logger().Warnf("ignoring invalid $%s value %s: %s", IndexCacheTTLEnvVar, v, err.Error())
+	}
+	return DefaultIndexCacheTTL
+}
+
+// Load returns the managed plugins followed by the plugins of each configured index in order.
+//
+// Indexes which cannot be loaded are logged and ignored so that one broken index does not hide the others
+func (l *IndexLoader) Load() []*IndexPlugin {
+	if l.Refresh && l.CacheDir != "" {
+		ForgetUnknownPlugins(l.CacheDir)
+	}
+	var answer []*IndexPlugin
+	for i := range Plugins {
+		answer = append(answer, &IndexPlugin{Index: ManagedIndexName, Plugin: Plugins[i]})
+	}
+	for i := range l.Indexes {
+		idx := &l.Indexes[i]
+		list, err := l.LoadIndex(idx)
+		if err != nil {
+			log.Logger().Warnf("ignoring plugin index %s: %s", idx.Name, err.Error())
+			continue
+		}
+		for j := range list {
+			answer = append(answer, &IndexPlugin{Index: idx.Name, Plugin: list[j]})
+		}
+	}
+	return answer
+}
+
+// LoadIndex returns the plugins of the given index fetching it if the cached copy has expired
+func (l *IndexLoader) LoadIndex(idx *config.PluginIndex) ([]jenkinsv1.Plugin, error) {
+	switch {
+	case idx.Name == "":
+		return nil, fmt.Errorf("plugin index has no name")
+	case idx.Name == ManagedIndexName:
+		return nil, fmt.Errorf("the plugin index name %s is reserved for the managed plugins", ManagedIndexName)
+	case strings.ContainsAny(idx.Name, `/\`) || idx.Name == "." || idx.Name == "..":
+		return nil, fmt.Errorf("invalid plugin index name %s", idx.Name)
+	case idx.URL != "" && idx.Git != "":
+		return nil, fmt.Errorf("plugin index %s must have either a url or a git repository but not both", idx.Name)
+	case idx.URL != "":
+		return l.loadHTTPIndex(idx)
+	case idx.Git != "":
+		return l.loadGitIndex(idx)
+	default:
+		return nil, fmt.Errorf("plugin index %s has no url or git repository", idx.Name)
+	}
+}
+
+func (l *IndexLoader) loadHTTPIndex(idx *config.PluginIndex) ([]jenkinsv1.Plugin, error) {
+	cacheFile := ""
+	if l.CacheDir != "" {
+		cacheFile = filepath.Join(l.CacheDir, indexCacheDirName, idx.Name+".yaml")
+		if IsOffline() || l.CacheOnly || (!l.Refresh && l.fresh(cacheFile)) {
+			data, err := os.ReadFile(cacheFile)
+			if err == nil {
+				return ParseIndex(data)
+			}
+		}
+	}
+
+	if IsOffline() || l.CacheOnly {
+		return nil, fmt.Errorf("plugin index %s has not been downloaded yet", idx.Name)
+	}
+	data, err := l.download(idx.URL)
+	if err == nil {
+		var answer []jenkinsv1.Plugin
+		answer, err = ParseIndex(data)
+		if err == nil {
+			if cacheFile != "" {
+				writeCacheFile(cacheFile, data)
+			}
+			return answer, nil
+		}
+	}
+	if cacheFile != "" {
+		cached, readErr := os.ReadFile(cacheFile)
+		if readErr == nil {
+			log.Logger().Warnf("failed to refresh plugin index %s, using the cached copy: %s", idx.Name, err.Error())
+			return ParseIndex(cached)
+		}
+	}
+	return nil, err
+}
+
+func (l *IndexLoader) download(u string) ([]byte, error) {
+	client := l.Client
+	if client == nil {
+		client = httphelpers.GetClient()
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to GET endpoint %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to GET endpoint %s with status %s", u, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", u, err)
+	}
+	return data, nil
+}
+
+func (l *IndexLoader) loadGitIndex(idx *config.PluginIndex) ([]jenkinsv1.Plugin, error) {
+	if l.CacheDir == "" {
+		return nil, fmt.Errorf("no cache dir to clone plugin index %s into", idx.Name)
+	}
+	runner := l.CommandRunner
+	if runner == nil {
+		runner = cmdrunner.QuietCommandRunner
+	}
+	indexesDir := filepath.Join(l.CacheDir, indexCacheDirName)
+	dir := filepath.Join(indexesDir, idx.Name)
+	if filepath.Dir(dir) != indexesDir {
+		// lets never remove anything outside of the directory of the index
+		return nil, fmt.Errorf("invalid plugin index name %s", idx.Name)
+	}
+	stampFile := dir + ".fetched"
+
+	cloned, err := files.DirExists(filepath.Join(dir, ".git"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if dir exists %s: %w", dir, err)
+	}
+	switch {
+	case !cloned && (IsOffline() || l.CacheOnly):
+		return nil, fmt.Errorf("plugin index %s has not been cloned yet", idx.Name)
+
+	case !cloned:
+		// lets remove any partial clone from a previous failure
+		err = os.RemoveAll(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove dir %s: %w", dir, err)
+		}
+		err = os.MkdirAll(filepath.Dir(dir), files.DefaultDirWritePermissions)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dir %s: %w", filepath.Dir(dir), err)
+		}
+		_, err = runner(&cmdrunner.Command{
+			Name: "git",
+			Args: []string{"clone", "--depth", "1", idx.Git, dir},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to clone plugin index %s: %w", idx.Git, err)
+		}
+		writeCacheFile(stampFile, nil)
+
+	case !IsOffline() && !l.CacheOnly && (l.Refresh || !l.fresh(stampFile)):
+		_, err = runner(&cmdrunner.Command{
+			Dir:  dir,
+			Name: "git",
+			Args: []string{"pull", "--ff-only"},
+		})
+		if err != nil {
+			log.Logger().Warnf("failed to refresh plugin index %s, using the cached copy: %s", idx.Name, err.Error())
+		} else {
+			writeCacheFile(stampFile, nil)
+		}
+	}
+
+	path := filepath.Join(dir, idx.Path)
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, fmt.Errorf("plugin index %s path %s is outside of the repository", idx.Name, idx.Path)
+	}
+	return LoadIndexPath(path)
+}
+
+// fresh returns true if the cache file was written within the cache TTL
+func (l *IndexLoader) fresh(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) < l.CacheTTL
+}
+
+// LoadIndexPath loads the plugins in the YAML file or the *.yaml and *.yml files in the directory
+func LoadIndexPath(path string) ([]jenkinsv1.Plugin, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find plugin index %s: %w", path, err)
+	}
+	fileNames := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dir %s: %w", path, err)
+		}
+		fileNames = nil
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if !e.IsDir() && (ext == ".yaml" || ext == ".yml") {
+				fileNames = append(fileNames, filepath.Join(path, e.Name()))
+			}
+		}
+	}
+
+	var answer []jenkinsv1.Plugin
+	for _, f := range fileNames {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f, err)
+		}
+		list, err := ParseIndex(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", f, err)
+		}
+		answer = append(answer, list...)
+	}
+	return answer, nil
+}
+
+// ParseIndex parses the plugins in a YAML list of jenkins.io/v1 Plugin resources, a PluginList or one or
+// more Plugin documents.
+//
+// Plugins without a valid jx- binary name, a semantic version or any binaries are logged and ignored
+func ParseIndex(data []byte) ([]jenkinsv1.Plugin, error) {
+	var answer []jenkinsv1.Plugin
+	for _, doc := range yamlDocumentSeparator.Split(string(data), -1) {
+		list, err := parseIndexDocument([]byte(doc))
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			p := &list[i]
+			switch {
+			case !strings.HasPrefix(p.Spec.Name, "jx-"):
+				log.Logger().Warnf("ignoring plugin %s in index as its binary name %s does not start with jx-", p.Name, p.Spec.Name)
+			case !ValidBinaryName(p.Spec.Name):
+				log.Logger().Warnf("ignoring plugin %s in index as its binary name %s may only contain lower case letters, digits and dashes", p.Name, p.Spec.Name)
+			case p.Spec.Version == "" || len(p.Spec.Binaries) == 0:
+				log.Logger().Warnf("ignoring plugin %s in index as it has no version or binaries", p.Spec.Name)
+			case ValidateVersion(p.Spec.Version) != nil:
+				log.Logger().Warnf("ignoring plugin %s in index as its version %s is not a semantic version", p.Spec.Name, p.Spec.Version)
+			default:
+				if p.Name == "" {
+					p.Name = p.Spec.Name
+				}
+				answer = append(answer, *p)
+			}
+		}
+	}
+	return answer, nil
+}
+
+func parseIndexDocument(data []byte) ([]jenkinsv1.Plugin, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] == '-' || data[0] == '[' {
+		var list []jenkinsv1.Plugin
+		err := yaml.Unmarshal(data, &list)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal plugin list: %w", err)
+		}
+		return list, nil
+	}
+
+	pluginList := &jenkinsv1.PluginList{}
+	err := yaml.Unmarshal(data, pluginList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plugins: %w", err)
+	}
+	if pluginList.Kind == "PluginList" || pluginList.Kind == "List" || len(pluginList.Items) > 0 {
+		return pluginList.Items, nil
+	}
+	plugin := jenkinsv1.Plugin{}
+	err = yaml.Unmarshal(data, &plugin)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal plugin: %w", err)
+	}
+	return []jenkinsv1.Plugin{plugin}, nil
+}
+
+// SearchIndex returns the plugins whose name, binary name, sub command or description contain the term ignoring case.
+// An empty term matches every plugin
+func SearchIndex(list []*IndexPlugin, term string) []*IndexPlugin {
+	term = strings.ToLower(term)
+	var answer []*IndexPlugin
+	for _, p := range list {
+		spec := &p.Plugin.Spec
+		for _, text := range []string{p.Plugin.Name, spec.Name, spec.SubCommand, spec.Description} {
+			if strings.Contains(strings.ToLower(text), term) {
+				answer = append(answer, p)
+				break
+			}
+		}
+	}
+	return answer
+}
+
+// FindIndexPlugin returns the first plugin with the given name such as gitops or jx-gitops in the given index or
+// in any index if the index is empty. Returns nil if there is no such plugin
+func FindIndexPlugin(list []*IndexPlugin, name, index string) *IndexPlugin {
+	binaryName := BinaryName(name)
+	for _, p := range list {
+		if index != "" && p.Index != index {
+			continue
+		}
+		if p.Plugin.Spec.Name == binaryName || p.Plugin.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// writeCacheFile writes the cache file logging any failures as the cache is only an optimisation
+func writeCacheFile(path string, data []byte) {
+	err := os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	if err == nil {
+		err = os.WriteFile(path, data, files.DefaultFileWritePermissions)
+	}
+	if err == nil {
+		// an empty file is not modified by truncating it so lets update its modification time explicitly
+		now := time.Now()
+		err = os.Chtimes(path, now, now)
+	}
+	if err != nil {
+		log.Logger().Debugf("failed to write cache file %s: %s", path, err.Error())
+	}
+}
@@ -0,0 +1,237 @@
+package plugins_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/cmdrunner"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIndex = `
+- apiVersion: jenkins.io/v1
+  kind: Plugin
+  metadata:
+    name: mytool
+  spec:
+    name: jx-mytool
+    version: 1.2.3
+    description: deploys things for the platform team
+    binaries:
+    - goarch: amd64
+      goos: Linux
+      url: https://example.com/jx-mytool-linux-amd64.tar.gz
+- apiVersion: jenkins.io/v1
+  kind: Plugin
+  metadata:
+    name: broken
+  spec:
+    name: broken
+    version: 1.0.0
+`
+
+func TestParseIndexFormats(t *testing.T) {
+	t.Parallel()
+
+	pluginList := `apiVersion: jenkins.io/v1
+kind: PluginList
+items:
+- metadata:
+    name: a
+  spec:
+    name: jx-a
+    version: 0.0.1
+    binaries:
+    - url: https://example.com/a.tar.gz
+`
+	documents := `spec:
+  name: jx-b
+  version: 0.0.2
+  binaries:
+  - url: https://example.com/b.tar.gz
+---
+spec:
+  name: jx-c
+  version: 0.0.3
+  binaries:
+  - url: https://example.com/c.tar.gz
+`
+	invalid := `- spec:
+    name: jx-../../evil
+    version: 1.0.0
+    binaries:
+    - url: https://example.com/evil.tar.gz
+- spec:
+    name: jx-Evil
+    version: 1.0.0
+    binaries:
+    - url: https://example.com/evil.tar.gz
+- spec:
+    name: jx-evil
+    version: 1/../../../tmp/evil
+    binaries:
+    - url: https://example.com/evil.tar.gz
+- spec:
+    name: jx-good
+    version: v1.0.0
+    binaries:
+    - url: https://example.com/good.tar.gz
+`
+	testCases := map[string][]string{
+		testIndex:  {"jx-mytool"},
+		pluginList: {"jx-a"},
+		documents:  {"jx-b", "jx-c"},
+		invalid:    {"jx-good"},
+	}
+	for data, expected := range testCases {
+		list, err := plugins.ParseIndex([]byte(data))
+		require.NoError(t, err, "failed to parse %s", data)
+
+		var names []string
+		for i := range list {
+			names = append(names, list[i].Spec.Name)
+			assert.NotEmpty(t, list[i].Name, "plugin name should default to the binary name")
+		}
+		assert.Equal(t, expected, names, "for index %s", data)
+	}
+
+	_, err := plugins.ParseIndex([]byte("- spec: [invalid"))
+	assert.Error(t, err)
+}
+
+func TestIndexLoaderHTTP(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	var fail atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, testIndex)
+	}))
+	defer server.Close()
+
+	l := &plugins.IndexLoader{
+		Indexes:  []config.PluginIndex{{Name: "platform", URL: server.URL + "/plugins.yaml"}},
+		CacheDir: t.TempDir(),
+		CacheTTL: time.Hour,
+		Client:   server.Client(),
+	}
+	list := l.Load()
+	p := plugins.FindIndexPlugin(list, "mytool", "")
+	require.NotNil(t, p, "should have found mytool")
+	assert.Equal(t, "platform", p.Index)
+	assert.Equal(t, "1.2.3", p.Plugin.Spec.Version)
+	assert.Len(t, list, len(plugins.Plugins)+1)
+
+	// the cached copy is used until it expires
+	l.Load()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// falls back to the cached copy if the index cannot be fetched
+	fail.Store(true)
+	l.Refresh = true
+	list = l.Load()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.NotNil(t, plugins.FindIndexPlugin(list, "jx-mytool", "platform"))
+	assert.Nil(t, plugins.FindIndexPlugin(list, "mytool", plugins.ManagedIndexName))
+	assert.NotNil(t, plugins.FindIndexPlugin(list, "gitops", plugins.ManagedIndexName))
+}
+
+func TestIndexLoaderCacheOnly(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, testIndex)
+	}))
+	defer server.Close()
+
+	idx := &config.PluginIndex{Name: "platform", URL: server.URL + "/plugins.yaml"}
+	l := &plugins.IndexLoader{CacheDir: t.TempDir(), CacheTTL: time.Nanosecond, Client: server.Client(), CacheOnly: true}
+	_, err := l.LoadIndex(idx)
+	assert.Error(t, err, "should fail if the index has not been downloaded")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&requests))
+
+	l.CacheOnly = false
+	_, err = l.LoadIndex(idx)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	l.CacheOnly = true
+	list, err := l.LoadIndex(idx)
+	require.NoError(t, err)
+	assert.Len(t, list, 1, "should use the expired cached copy")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "should not use the network")
+}
+
+func TestIndexLoaderGit(t *testing.T) {
+	t.Parallel()
+
+	var commands []string
+	runner := func(c *cmdrunner.Command) (string, error) {
+		commands = append(commands, c.Args[0])
+		if c.Args[0] == "clone" {
+			dir := c.Args[len(c.Args)-1]
+			err := os.MkdirAll(filepath.Join(dir, ".git"), 0o700)
+			if err == nil {
+				err = os.MkdirAll(filepath.Join(dir, "plugins"), 0o700)
+			}
+			if err == nil {
+				err = os.WriteFile(filepath.Join(dir, "plugins", "mytool.yaml"), []byte(testIndex), 0o600)
+			}
+			return "", err
+		}
+		return "", nil
+	}
+
+	l := &plugins.IndexLoader{
+		Indexes:       []config.PluginIndex{{Name: "platform", Git: "https://example.com/index.git", Path: "plugins"}},
+		CacheDir:      t.TempDir(),
+		CacheTTL:      time.Hour,
+		CommandRunner: runner,
+	}
+	results := plugins.SearchIndex(l.Load(), "PLATFORM team")
+	require.Len(t, results, 1)
+	assert.Equal(t, "jx-mytool", results[0].Plugin.Spec.Name)
+
+	l.Load()
+	l.Refresh = true
+	l.Load()
+	assert.Equal(t, []string{"clone", "pull"}, commands)
+
+	_, err := l.LoadIndex(&config.PluginIndex{Name: "escape", Git: "https://example.com/index.git", Path: "../.."})
+	assert.Error(t, err)
+}
+
+func TestIndexLoaderInvalidIndexes(t *testing.T) {
+	t.Parallel()
+
+	l := &plugins.IndexLoader{CacheDir: t.TempDir()}
+	for _, idx := range []config.PluginIndex{
+		{URL: "https://example.com/plugins.yaml"},
+		{Name: plugins.ManagedIndexName, URL: "https://example.com/plugins.yaml"},
+		{Name: "both", URL: "https://example.com/plugins.yaml", Git: "https://example.com/index.git"},
+		{Name: "neither"},
+		{Name: ".", Git: "https://example.com/index.git"},
+		{Name: "..", Git: "https://example.com/index.git"},
+		{Name: "../other", Git: "https://example.com/index.git"},
+	} {
+		_, err := l.LoadIndex(&idx)
+		assert.Error(t, err, "for index %#v", idx)
+	}
+	assert.DirExists(t, l.CacheDir, "should not remove the cache dir")
+}
@@ -0,0 +1,203 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+var (
+	installedPluginPattern  = regexp.MustCompile("^(jx-.*)-([0-9.]+)$")
+	pluginBinaryNamePattern = regexp.MustCompile("^jx-[a-z0-9-]+$")
+)
+
+// PluginInfo describes a plugin along with the versions of it which are installed
+type PluginInfo struct {
+	// Name the name of the command such as gitops
+	Name string `json:"name"`
+
+	// Binary the name of the plugin binary such as jx-gitops
+	Binary string `json:"binary"`
+
+	// Managed true if the plugin version is pinned by this version of jx
+	Managed bool `json:"managed"`
+
+	// Version the pinned version of a managed plugin
+	Version string `json:"version,omitempty"`
+
+	// Description the description of the plugin
+	Description string `json:"description,omitempty"`
+
+	// InstalledVersions the versions installed in the plugin bin dir, newest first
+	InstalledVersions []string `json:"installedVersions,omitempty"`
+
+	// Path the binary used when invoking the plugin or empty if it is not installed
+	Path string `json:"path,omitempty"`
+
+	// PathBinary the binary with the same name found on the PATH if any
+	PathBinary string `json:"pathBinary,omitempty"`
+
+	// Shadowed true if a binary outside of the plugin bin dir is used rather than the versions installed in it
+	Shadowed bool `json:"shadowed"`
+
+	// Source the repository the plugin was installed from if any such as github.com/myorg/jx-mytool
+	Source string `json:"source,omitempty"`
+}
+
+// BinaryName returns the plugin binary name for a plugin name like gitops or jx-gitops
+func BinaryName(name string) string {
+	if strings.HasPrefix(name, "jx-") {
+		return name
+	}
+	return "jx-" + name
+}
+
+// ValidBinaryName returns true if the plugin binary name such as jx-gitops is safe to use as a file name
+func ValidBinaryName(binaryName string) bool {
+	return pluginBinaryNamePattern.MatchString(binaryName)
+}
+
+// ValidateVersion returns an error if the plugin version, which may have a v prefix, is not a semantic version
+// as the version is used in the file name of the installed binary
+func ValidateVersion(version string) error {
+	if strings.ContainsAny(version, `/\`) || strings.Contains(version, "..") {
+		return fmt.Errorf("version %s must not contain path separators", version)
+	}
+	_, err := semver.Parse(strings.TrimPrefix(version, "v"))
+	if err != nil {
+		return fmt.Errorf("version %s is not a semantic version: %w", version, err)
+	}
+	return nil
+}
+
+// InstalledPlugins returns the versions of all the plugins installed in the plugin bin dir indexed by binary name
+func InstalledPlugins(pluginBinDir string) (map[string][]string, error) {
+	entries, err := os.ReadDir(pluginBinDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin dir %s: %w", pluginBinDir, err)
+	}
+	m := map[string][]string{}
+	for _, e := range entries {
+		res := installedPluginPattern.FindStringSubmatch(e.Name())
+		if len(res) > 2 && !e.IsDir() {
+			m[res[1]] = append(m[res[1]], res[2])
+		}
+	}
+	for name, versions := range m {
+		m[name] = sortVersions(versions)
+	}
+	return m, nil
+}
+
+// InstalledVersions returns the semantic versions of the plugin binary installed in the plugin bin dir, newest first
+func InstalledVersions(pluginBinDir, binaryName string) ([]string, error) {
+	m, err := InstalledPlugins(pluginBinDir)
+	if err != nil {
+		return nil, err
+	}
+	return m[binaryName], nil
+}
+
+// sortVersions returns the valid semantic versions sorted newest first
+func sortVersions(versions []string) []string {
+	vs := make([]semver.Version, 0, len(versions))
+	for _, r := range versions {
+		v, err := semver.Parse(r)
+		if err == nil {
+			vs = append(vs, v)
+		}
+	}
+	sort.Sort(sort.Reverse(semver.Versions(vs)))
+	answer := make([]string, 0, len(vs))
+	for _, v := range vs {
+		answer = append(answer, v.String())
+	}
+	return answer
+}
+
+// GetPluginInfo returns the information about the given plugin without installing anything
+func GetPluginInfo(pluginBinDir, name string) (*PluginInfo, error) {
+	binaryName := BinaryName(name)
+	versions, err := InstalledVersions(pluginBinDir, binaryName)
+	if err != nil {
+		return nil, err
+	}
+	info := newPluginInfo(pluginBinDir, binaryName, versions)
+	setSources([]*PluginInfo{info})
+	return info, nil
+}
+
+// ListPlugins returns the information about all the managed plugins and the plugins installed in the plugin bin dir
+func ListPlugins(pluginBinDir string) ([]*PluginInfo, error) {
+	installed, err := InstalledPlugins(pluginBinDir)
+	if err != nil {
+		return nil, err
+	}
+	var answer []*PluginInfo
+	for i := range Plugins {
+		binaryName := Plugins[i].Spec.Name
+		answer = append(answer, newPluginInfo(pluginBinDir, binaryName, installed[binaryName]))
+	}
+	var names []string
+	for binaryName := range installed {
+		if PluginMap[binaryName] == nil {
+			names = append(names, binaryName)
+		}
+	}
+	sort.Strings(names)
+	for _, binaryName := range names {
+		answer = append(answer, newPluginInfo(pluginBinDir, binaryName, installed[binaryName]))
+	}
+	setSources(answer)
+	return answer, nil
+}
+
+// setSources sets the repositories the plugins were installed from
+func setSources(list []*PluginInfo) {
+	sources, err := LoadSources()
+	if err != nil {
+		log.Logger().Debugf("failed to load plugin sources: %s", err.Error())
+		return
+	}
+	for _, info := range list {
+		if src := sources.Find(info.Binary); src != nil {
+			info.Source = src.String()
+		}
+	}
+}
+
+func newPluginInfo(pluginBinDir, binaryName string, versions []string) *PluginInfo {
+	info := &PluginInfo{
+		Name:              strings.TrimPrefix(binaryName, "jx-"),
+		Binary:            binaryName,
+		InstalledVersions: versions,
+	}
+	info.PathBinary, _ = exec.LookPath(binaryName)
+
+	searchPath := NewSearchPath(pluginBinDir)
+	plugin := PluginMap[binaryName]
+	if plugin != nil {
+		// managed plugins always use the pinned version
+		info.Managed = true
+		info.Version = plugin.Spec.Version
+		info.Description = plugin.Spec.Description
+		if l := searchPath.FindVersion(binaryName, info.Version); l != nil {
+			info.Path = l.Path
+		}
+		return info
+	}
+
+	// unmanaged plugins use the binary with the highest precedence in the search path such as a local build
+	if l := searchPath.Find(binaryName); l != nil {
+		info.Path = l.Path
+		info.Shadowed = len(versions) > 0 && l.Dir != filepath.Clean(pluginBinDir)
+	}
+	return info
+}
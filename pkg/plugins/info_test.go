@@ -0,0 +1,54 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createPluginBinaries creates fake plugin binaries in a temporary plugin bin dir
+func createPluginBinaries(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0o600)
+		require.NoError(t, err, "failed to create %s", name)
+	}
+	return dir
+}
+
+func TestInstalledPlugins(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinaries(t, "jx-foo-1.0.0", "jx-foo-1.10.0", "jx-foo-1.2.0", "jx-foo-bar-0.0.1", "jx-gitops-1.0.0", "something-else")
+
+	installed, err := plugins.InstalledPlugins(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"jx-foo":     {"1.10.0", "1.2.0", "1.0.0"},
+		"jx-foo-bar": {"0.0.1"},
+		"jx-gitops":  {"1.0.0"},
+	}, installed)
+}
+
+func TestGetPluginInfo(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinaries(t, "jx-gitops-0.0.1", "jx-gitops-"+plugins.GitOpsVersion, "jx-doesnotexist-1.0.0", "jx-doesnotexist-2.0.0")
+
+	info, err := plugins.GetPluginInfo(dir, "gitops")
+	require.NoError(t, err)
+	assert.True(t, info.Managed, "gitops should be managed")
+	assert.Equal(t, plugins.GitOpsVersion, info.Version)
+	assert.Equal(t, plugins.PluginBinary(dir, "jx-gitops", plugins.GitOpsVersion), info.Path)
+
+	info, err = plugins.GetPluginInfo(dir, "jx-doesnotexist")
+	require.NoError(t, err)
+	assert.False(t, info.Managed, "should not be managed")
+	assert.Equal(t, []string{"2.0.0", "1.0.0"}, info.InstalledVersions)
+	assert.Equal(t, plugins.PluginBinary(dir, "jx-doesnotexist", "2.0.0"), info.Path, "should use the newest version")
+	assert.False(t, info.Shadowed)
+}
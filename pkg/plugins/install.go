@@ -0,0 +1,315 @@
+package plugins
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/signature"
+	"github.com/rhysd/go-github-selfupdate/selfupdate"
+)
+
+const binaryFileMode os.FileMode = 0o755
+
+// Installer downloads, verifies and installs plugin binaries into the plugin bin dir
+type Installer struct {
+	// Lock the lock the downloaded plugin archives are verified against
+	Lock *Lock
+
+	// Verifier verifies the detached signatures of the downloaded plugin archives
+	Verifier *signature.Verifier
+
+	// SkipVerify disables the verification of signatures
+	SkipVerify bool
+
+	// Mirror the optional artifact mirror plugins are looked up and downloaded from
+	Mirror *config.Mirror
+
+	// Client the HTTP client used to download plugins
+	Client *http.Client
+
+	// Token the token used to authenticate GitHub API requests. If empty it is looked up from the git credentials
+	Token string
+
+	// CacheDir the directory used to cache latest releases or empty to disable caching
+	CacheDir string
+
+	// CacheTTL how long latest releases are cached before they are revalidated
+	CacheTTL time.Duration
+
+	// Progress the optional reporter of the progress of downloads. If nil each install is logged
+	Progress Progress
+
+	// Sources the repositories plugins were installed from which they are upgraded from
+	Sources *Sources
+
+	// Metadata the optional loader which caches the metadata of installed plugins so that the help and completion
+	// of jx never has to invoke plugins
+	Metadata *MetadataLoader
+
+	// mu guards the lazily initialised fields and the lock so that plugins can be installed concurrently
+	mu            sync.Mutex
+	tokenResolved bool
+}
+
+// NewInstaller creates a new installer which verifies plugins against the plugin lock and the trusted public keys
+func NewInstaller() (*Installer, error) {
+	lock, err := LoadLock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin lock: %w", err)
+	}
+	verifier, err := signature.NewVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trusted public keys: %w", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jx configuration: %w", err)
+	}
+	cacheDir, err := config.CacheDir()
+	if err != nil {
+		return nil, err
+	}
+	sources, err := LoadSources()
+	if err != nil {
+		// lets not stop the managed plugins being installed
+		log.Logger().Warnf("ignoring plugin sources: %s", err.Error())
+	}
+	return &Installer{
+		Lock:       lock,
+		Verifier:   verifier,
+		SkipVerify: !signature.VerifyEnabled(),
+		Mirror:     &cfg.Mirror,
+		Client:     httphelpers.GetClient(),
+		Token:      os.Getenv(GitHubTokenEnvVar),
+		CacheDir:   cacheDir,
+		CacheTTL:   ReleaseCacheTTL(),
+		Sources:    sources,
+		Metadata:   NewMetadataLoader(),
+	}, nil
+}
+
+// EnsurePluginInstalled makes sure the given plugin version is installed verifying the downloaded archive
+// against the plugin lock and returns the path to the binary
+func EnsurePluginInstalled(plugin jenkinsv1.Plugin, pluginBinDir string) (string, error) {
+	installer, err := NewInstaller()
+	if err != nil {
+		return "", err
+	}
+	return installer.EnsurePluginInstalled(plugin, pluginBinDir)
+}
+
+// PluginBinary returns the path of the binary of the given plugin version in the plugin bin dir
+func PluginBinary(pluginBinDir, name, version string) string {
+	return filepath.Join(pluginBinDir, name+"-"+version)
+}
+
+// PluginURL returns the URL of the plugin archive for the current platform
+func PluginURL(spec *jenkinsv1.PluginSpec) (string, error) {
+	for _, b := range spec.Binaries {
+		if strings.EqualFold(b.Goos, runtime.GOOS) && strings.EqualFold(b.Goarch, runtime.GOARCH) {
+			return b.URL, nil
+		}
+	}
+	return "", fmt.Errorf("plugin %s version %s has no binary for %s", spec.Name, spec.Version, Platform())
+}
+
+// EnsurePluginInstalled makes sure the given plugin version is installed verifying the downloaded archive
+// against the plugin lock and returns the path to the binary
+func (i *Installer) EnsurePluginInstalled(plugin jenkinsv1.Plugin, pluginBinDir string) (string, error) {
+	spec := &plugin.Spec
+	path := PluginBinary(pluginBinDir, spec.Name, spec.Version)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if file exists %s: %w", path, err)
+	}
+	if exists {
+		return path, nil
+	}
+	if IsOffline() {
+		return "", &OfflineError{Binary: spec.Name, Version: spec.Version}
+	}
+
+	u, err := PluginURL(spec)
+	if err != nil {
+		return "", err
+	}
+	u = i.Mirror.DownloadURL(u)
+
+	var onProgress func(downloaded, total int64)
+	if i.Progress != nil {
+		i.Progress.Start(spec.Name, spec.Version)
+		onProgress = func(downloaded, total int64) {
+			i.Progress.Update(spec.Name, downloaded, total)
+		}
+	} else {
+		log.Logger().Infof("installing plugin %s version %s from %s", termcolor.ColorInfo(spec.Name), termcolor.ColorInfo(spec.Version), u)
+	}
+	err = i.install(spec, u, path, onProgress)
+	if i.Progress != nil {
+		i.Progress.Done(spec.Name, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	if i.Metadata != nil {
+		_, err = i.Metadata.Load(path)
+		if err != nil {
+			log.Logger().Debugf("failed to load metadata of plugin %s: %s", spec.Name, err.Error())
+		}
+	}
+	return path, nil
+}
+
+// install downloads, verifies and extracts the plugin archive at the given URL or OCI reference
+func (i *Installer) install(spec *jenkinsv1.PluginSpec, u, path string, onProgress func(downloaded, total int64)) error {
+	var data []byte
+	var err error
+	archiveName := u
+	if IsOCI(u) {
+		data, archiveName, err = i.pullOCI(u, onProgress)
+		if err != nil {
+			return err
+		}
+	} else {
+		data, err = download(i.Client, u, onProgress)
+		if err != nil {
+			return err
+		}
+		if i.Sources.SkipVerify(spec.Name) {
+			log.Logger().Debugf("skipping signature verification of %s as it was installed from a repository with --insecure-skip-verify", u)
+		} else {
+			err = i.verifySignature(u, data, func() ([]byte, error) {
+				return Download(i.Client, u+signature.Suffix)
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	i.mu.Lock()
+	err = i.Lock.VerifyChecksum(spec.Name, spec.Version, Platform(), data)
+	i.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", u, err)
+	}
+	err = extractBinary(data, archiveName, spec.Name, path)
+	if err != nil {
+		return fmt.Errorf("failed to install plugin %s version %s: %w", spec.Name, spec.Version, err)
+	}
+	return nil
+}
+
+// verifySignature verifies the detached signature of the archive downloaded from the given URL
+func (i *Installer) verifySignature(u string, data []byte, downloadSignature func() ([]byte, error)) error {
+	if i.SkipVerify {
+		log.Logger().Debugf("skipping signature verification of %s", u)
+		return nil
+	}
+	i.mu.Lock()
+	if i.Verifier == nil {
+		verifier, err := signature.NewVerifier()
+		if err != nil {
+			i.mu.Unlock()
+			return fmt.Errorf("failed to load trusted public keys: %w", err)
+		}
+		i.Verifier = verifier
+	}
+	i.mu.Unlock()
+	sig, err := downloadSignature()
+	if err != nil {
+		return fmt.Errorf("failed to download signature of %s: %w", u, err)
+	}
+	err = i.Verifier.Verify(data, sig)
+	if err != nil {
+		return fmt.Errorf("failed to verify signature of %s: %w", u, err)
+	}
+	return nil
+}
+
+// Download downloads the given URL returning the response body
+func Download(client *http.Client, u string) ([]byte, error) {
+	return download(client, u, nil)
+}
+
+// download downloads the given URL reporting the progress to the optional callback
+func download(client *http.Client, u string, onProgress func(downloaded, total int64)) ([]byte, error) {
+	if client == nil {
+		client = httphelpers.GetClient()
+	}
+	resp, err := client.Get(u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: status %s", u, resp.Status)
+	}
+	var r io.Reader = resp.Body
+	if onProgress != nil {
+		r = &progressReader{r: resp.Body, total: max(resp.ContentLength, 0), onProgress: onProgress}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	return data, nil
+}
+
+// extractBinary extracts the binary of the plugin from the archive and writes it to the path
+func extractBinary(data []byte, u, name, path string) error {
+	binaryName := name
+	if runtime.GOOS == "windows" {
+		binaryName += ".exe"
+	}
+	r, err := selfupdate.UncompressCommand(bytes.NewReader(data), u, binaryName)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s from %s: %w", binaryName, u, err)
+	}
+
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create dir %s: %w", dir, err)
+	}
+
+	// lets write to a temporary file first so that we never leave a partial binary behind
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file in %s: %w", dir, err)
+	}
+	tmpFile := f.Name()
+	defer os.Remove(tmpFile) //nolint:errcheck
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", tmpFile, err)
+	}
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close %s: %w", tmpFile, err)
+	}
+	err = os.Chmod(tmpFile, binaryFileMode)
+	if err != nil {
+		return fmt.Errorf("failed to make %s executable: %w", tmpFile, err)
+	}
+	err = os.Rename(tmpFile, path)
+	if err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", tmpFile, path, err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package plugins_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/jenkins-x/jx/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createArchive creates a tar.gz archive containing a single binary
+func createArchive(t *testing.T, name string, content []byte) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content))})
+	require.NoError(t, err)
+	_, err = tw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// createPlugin creates a plugin with a binary for the current platform served from the given URL
+func createPlugin(name, version, u string) jenkinsv1.Plugin {
+	return jenkinsv1.Plugin{
+		Spec: jenkinsv1.PluginSpec{
+			Name:    name,
+			Version: version,
+			Binaries: []jenkinsv1.Binary{
+				{
+					Goos:   runtime.GOOS,
+					Goarch: runtime.GOARCH,
+					URL:    u,
+				},
+			},
+		},
+	}
+}
+
+// serveSignedArchive serves the archive along with a detached signature created by the key
+func serveSignedArchive(t *testing.T, archive []byte, key *ecdsa.PrivateKey) *httptest.Server {
+	digest := sha256.Sum256(archive)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if filepath.Ext(r.URL.Path) == signature.Suffix {
+			w.Write(sig) //nolint:errcheck
+			return
+		}
+		w.Write(archive) //nolint:errcheck
+	}))
+}
+
+func TestInstallerVerifiesChecksum(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	archive := createArchive(t, "jx-foo", []byte("#!/bin/sh\necho foo\n"))
+	server := serveSignedArchive(t, archive, key)
+	defer server.Close()
+
+	plugin := createPlugin("jx-foo", "1.0.0", server.URL+"/jx-foo-linux-amd64.tar.gz")
+
+	lock := &plugins.Lock{Strict: true, Path: filepath.Join(t.TempDir(), "plugins.lock")}
+	lock.SetChecksum("jx-foo", "1.0.0", plugins.Platform(), "0000")
+	installer := &plugins.Installer{
+		Lock:     lock,
+		Verifier: &signature.Verifier{Keys: []*ecdsa.PublicKey{&key.PublicKey}},
+		Client:   server.Client(),
+		Metadata: &plugins.MetadataLoader{CacheDir: t.TempDir()},
+	}
+
+	pluginBinDir := t.TempDir()
+	_, err = installer.EnsurePluginInstalled(plugin, pluginBinDir)
+	require.Error(t, err, "should fail to install an archive with the wrong checksum")
+	assert.Contains(t, err.Error(), "checksum mismatch")
+	assert.NoFileExists(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.0.0"))
+
+	lock.SetChecksum("jx-foo", "1.0.0", plugins.Platform(), plugins.Checksum(archive))
+	path, err := installer.EnsurePluginInstalled(plugin, pluginBinDir)
+	require.NoError(t, err, "should install an archive with the locked checksum")
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.0.0"), path)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err, "failed to read installed binary")
+	assert.Equal(t, "#!/bin/sh\necho foo\n", string(data))
+
+	cached, err := filepath.Glob(filepath.Join(installer.Metadata.CacheDir, "*.json"))
+	require.NoError(t, err)
+	assert.Len(t, cached, 1, "should cache the metadata of the installed plugin")
+}
+
+func TestInstallerVerifiesSignature(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	untrustedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	archive := createArchive(t, "jx-foo", []byte("#!/bin/sh\necho foo\n"))
+	server := serveSignedArchive(t, archive, untrustedKey)
+	defer server.Close()
+
+	plugin := createPlugin("jx-foo", "1.0.0", server.URL+"/jx-foo-linux-amd64.tar.gz")
+	installer := &plugins.Installer{
+		Lock:     &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+		Verifier: &signature.Verifier{Keys: []*ecdsa.PublicKey{&key.PublicKey}},
+		Client:   server.Client(),
+	}
+
+	pluginBinDir := t.TempDir()
+	_, err = installer.EnsurePluginInstalled(plugin, pluginBinDir)
+	require.Error(t, err, "should fail to install an archive signed by an untrusted key")
+	assert.Contains(t, err.Error(), "failed to verify signature")
+	assert.Empty(t, installer.Lock.Plugins, "should not record the checksum of an unverified archive")
+
+	installer.SkipVerify = true
+	_, err = installer.EnsurePluginInstalled(plugin, pluginBinDir)
+	assert.NoError(t, err, "should install when signature verification is skipped")
+}
+
+func TestInstallStandardPluginFromMirror(t *testing.T) {
+	t.Parallel()
+
+	archive := createArchive(t, "jx-foo", []byte("#!/bin/sh\necho foo\n"))
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		switch r.URL.Path {
+		case "/api/repos/jenkins-x-plugins/jx-foo/releases/latest":
+			w.Write([]byte(`{"tag_name": "v1.2.3"}`)) //nolint:errcheck
+		case "/github/jenkins-x-plugins/jx-foo/releases/download/v1.2.3/jx-foo-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.gz":
+			w.Write(archive) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	installer := &plugins.Installer{
+		Lock:       &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+		SkipVerify: true,
+		Mirror: &config.Mirror{
+			GitHubURL:    server.URL + "/github/",
+			GitHubAPIURL: server.URL + "/api/",
+		},
+		Client: server.Client(),
+	}
+
+	pluginBinDir := t.TempDir()
+	path, err := installer.InstallStandardPlugin(pluginBinDir, "jx-foo")
+	require.NoError(t, err, "failed to install from mirror, requested %v", paths)
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.2.3"), path)
+	assert.FileExists(t, path)
+}
@@ -0,0 +1,237 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// LockFileName the name of the plugin lock file in the jx home dir
+	LockFileName = "plugins.lock"
+
+	// LockFileEnvVar the environment variable used to override the location of the plugin lock file
+	LockFileEnvVar = "JX_PLUGINS_LOCK"
+)
+
+// defaultLockData the lock of the managed plugins which is generated via 'make plugins-lock'
+//
+//go:embed plugins.lock
+var defaultLockData []byte
+
+// Lock records the sha256 checksums of the plugin archives for each platform so that
+// downloaded plugins can be verified before they are installed
+type Lock struct {
+	// Strict if enabled plugins which have no checksum in the lock fail to install
+	// rather than having their checksum recorded on first use
+	Strict bool `json:"strict,omitempty"`
+
+	// Plugins the checksums of the plugins
+	Plugins []LockedPlugin `json:"plugins,omitempty"`
+
+	// Path the file the lock is loaded from and saved to
+	Path string `json:"-"`
+
+	defaults *Lock
+}
+
+// LockedPlugin the checksums of the archives of a plugin version
+type LockedPlugin struct {
+	// Name the name of the plugin binary such as jx-gitops
+	Name string `json:"name"`
+
+	// Version the version of the plugin
+	Version string `json:"version"`
+
+	// Checksums the sha256 checksums of the archives indexed by platform such as linux/amd64
+	Checksums map[string]string `json:"checksums"`
+}
+
+// Platform returns the platform key of the current OS and architecture used in the lock such as linux/amd64
+func Platform() string {
+	return runtime.GOOS + "/" + runtime.GOARCH
+}
+
+// LockFile returns the location of the users plugin lock file
+func LockFile() (string, error) {
+	path := os.Getenv(LockFileEnvVar)
+	if path != "" {
+		return path, nil
+	}
+	dir, err := config.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, LockFileName), nil
+}
+
+// LoadLock loads the users plugin lock file falling back to the lock embedded in the binary
+// for any plugins which are not in the users lock
+func LoadLock() (*Lock, error) {
+	defaults, err := DefaultLock()
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := LockFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the plugin lock file: %w", err)
+	}
+	lock, err := LoadLockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lock.defaults = defaults
+	return lock, nil
+}
+
+// DefaultLock returns the lock of the managed plugins embedded in the binary
+func DefaultLock() (*Lock, error) {
+	defaults := &Lock{}
+	err := yaml.Unmarshal(defaultLockData, defaults)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the default plugin lock: %w", err)
+	}
+	return defaults, nil
+}
+
+// IsDefaultPlugin returns true if the plugin version is one of the managed plugin versions compiled into the binary
+// whose checksums must be in the default lock
+func IsDefaultPlugin(name, version string) bool {
+	for i := range DefaultPlugins {
+		if DefaultPlugins[i].Spec.Name == name && DefaultPlugins[i].Spec.Version == version {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadLockFile loads the lock from the given file returning an empty lock if the file does not exist
+func LoadLockFile(path string) (*Lock, error) {
+	lock := &Lock{Path: path}
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if file exists %s: %w", path, err)
+	}
+	if !exists {
+		return lock, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugin lock %s: %w", path, err)
+	}
+	err = yaml.Unmarshal(data, lock)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plugin lock %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// Save saves the lock to its file
+func (l *Lock) Save() error {
+	if l.Path == "" {
+		return fmt.Errorf("no file to save the plugin lock to")
+	}
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin lock: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(l.Path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create dir for plugin lock %s: %w", l.Path, err)
+	}
+	err = os.WriteFile(l.Path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to save plugin lock %s: %w", l.Path, err)
+	}
+	return nil
+}
+
+// Checksum returns the locked checksum for the given plugin version and platform or an empty string if there is none
+func (l *Lock) Checksum(name, version, platform string) string {
+	for i := range l.Plugins {
+		p := &l.Plugins[i]
+		if p.Name == name && p.Version == version && p.Checksums[platform] != "" {
+			return p.Checksums[platform]
+		}
+	}
+	if l.defaults != nil {
+		return l.defaults.Checksum(name, version, platform)
+	}
+	return ""
+}
+
+// SetChecksum sets the checksum for the given plugin version and platform
+func (l *Lock) SetChecksum(name, version, platform, checksum string) {
+	for i := range l.Plugins {
+		p := &l.Plugins[i]
+		if p.Name == name && p.Version == version {
+			if p.Checksums == nil {
+				p.Checksums = map[string]string{}
+			}
+			p.Checksums[platform] = checksum
+			return
+		}
+	}
+	l.Plugins = append(l.Plugins, LockedPlugin{
+		Name:      name,
+		Version:   version,
+		Checksums: map[string]string{platform: checksum},
+	})
+}
+
+// VerifyChecksum verifies the downloaded archive of a plugin matches the checksum in the lock.
+//
+// The managed plugin versions compiled into the binary must match the checksums in the default lock. For other
+// plugins with no checksum in the lock the checksum is recorded in the lock file so that future installs
+// are verified, unless the lock is strict in which case an error is returned.
+//
+// Development builds whose default lock was never generated via 'make plugins-lock' treat the managed plugins
+// like other plugins, logging a warning, so that they can still install them. Releases always generate it
+func (l *Lock) VerifyChecksum(name, version, platform string, data []byte) error {
+	actual := Checksum(data)
+	expected := l.Checksum(name, version, platform)
+	if IsDefaultPlugin(name, version) {
+		defaults, err := DefaultLock()
+		if err != nil {
+			return err
+		}
+		if len(defaults.Plugins) == 0 {
+			log.Logger().Warnf("the plugin lock embedded in this development build of jx has not been generated via 'make plugins-lock' so managed plugin %s version %s is not verified against it", name, version)
+		} else {
+			// lets never trust the first download of a managed plugin
+			expected = defaults.Checksum(name, version, platform)
+			if expected == "" {
+				return fmt.Errorf("there is no checksum for managed plugin %s version %s on %s in the plugin lock embedded in jx so refusing to install it", name, version, platform)
+			}
+		}
+	}
+	if expected == "" {
+		if l.Strict {
+			return fmt.Errorf("there is no checksum for plugin %s version %s on %s in the plugin lock %s which is strict", name, version, platform, l.Path)
+		}
+		log.Logger().Debugf("recording checksum %s of plugin %s version %s on %s in %s", actual, name, version, platform, l.Path)
+		l.SetChecksum(name, version, platform, actual)
+		return l.Save()
+	}
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("checksum mismatch for plugin %s version %s on %s: expected sha256 %s but downloaded archive has sha256 %s. Refusing to install it", name, version, platform, expected, actual)
+	}
+	return nil
+}
+
+// Checksum returns the hex encoded sha256 checksum of the data
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
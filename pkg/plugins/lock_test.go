@@ -0,0 +1,81 @@
+package plugins_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockVerifyChecksum(t *testing.T) {
+	t.Parallel()
+
+	data := []byte("some plugin archive")
+	path := filepath.Join(t.TempDir(), "plugins.lock")
+	lock, err := plugins.LoadLockFile(path)
+	require.NoError(t, err, "failed to load lock")
+
+	err = lock.VerifyChecksum("jx-foo", "1.2.3", "linux/amd64", data)
+	require.NoError(t, err, "should record the checksum on first use")
+
+	lock, err = plugins.LoadLockFile(path)
+	require.NoError(t, err, "failed to reload lock")
+	assert.Equal(t, plugins.Checksum(data), lock.Checksum("jx-foo", "1.2.3", "linux/amd64"), "recorded checksum")
+
+	err = lock.VerifyChecksum("jx-foo", "1.2.3", "linux/amd64", data)
+	assert.NoError(t, err, "should verify the same archive")
+
+	err = lock.VerifyChecksum("jx-foo", "1.2.3", "linux/amd64", []byte("tampered"))
+	require.Error(t, err, "should fail for a different archive")
+	assert.Contains(t, err.Error(), "checksum mismatch")
+}
+
+func TestStrictLockRequiresChecksum(t *testing.T) {
+	t.Parallel()
+
+	lock := &plugins.Lock{Strict: true, Path: filepath.Join(t.TempDir(), "plugins.lock")}
+	err := lock.VerifyChecksum("jx-foo", "1.2.3", "linux/amd64", []byte("archive"))
+	assert.Error(t, err, "strict lock should fail for plugins without a checksum")
+}
+
+func TestManagedPluginsRequireDefaultChecksum(t *testing.T) {
+	t.Parallel()
+
+	p := plugins.DefaultPlugins[0].Spec
+	lock := &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")}
+	lock.SetChecksum(p.Name, p.Version, "linux/amd64", plugins.Checksum([]byte("archive")))
+	err := lock.VerifyChecksum(p.Name, p.Version, "linux/amd64", []byte("archive"))
+
+	defaults, lerr := plugins.DefaultLock()
+	require.NoError(t, lerr)
+	if len(defaults.Plugins) == 0 {
+		assert.NoError(t, err, "development builds without a generated default lock should use the users lock")
+	} else {
+		assert.Error(t, err, "should only trust the default lock for managed plugins")
+	}
+
+	err = lock.VerifyChecksum(p.Name, p.Version, "linux/amd64", []byte("tampered archive"))
+	assert.Error(t, err, "should never install a managed plugin whose checksum does not match")
+}
+
+func TestDefaultLockMatchesVersions(t *testing.T) {
+	t.Parallel()
+
+	lock, err := plugins.DefaultLock()
+	require.NoError(t, err)
+	for i := range plugins.DefaultPlugins {
+		spec := &plugins.DefaultPlugins[i].Spec
+		for _, b := range spec.Binaries {
+			platform := strings.ToLower(b.Goos) + "/" + strings.ToLower(b.Goarch)
+			assert.NotEmpty(t, lock.Checksum(spec.Name, spec.Version, platform),
+				"no checksum for %s version %s on %s in plugins.lock. Run: make plugins-lock", spec.Name, spec.Version, platform)
+		}
+	}
+	for _, p := range lock.Plugins {
+		assert.True(t, plugins.IsDefaultPlugin(p.Name, p.Version),
+			"plugins.lock contains %s version %s which is not in versions.go. Run: make plugins-lock", p.Name, p.Version)
+	}
+}
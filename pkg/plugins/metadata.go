@@ -0,0 +1,387 @@
+package plugins
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	// MetadataArg the hidden argument a plugin is invoked with to print its Metadata as JSON to stdout
+	MetadataArg = "__jx_metadata"
+
+	// DefaultMetadataTimeout how long a plugin is given to print its metadata
+	DefaultMetadataTimeout = 5 * time.Second
+
+	// UnsupportedMetadataTTL how long a plugin which failed to print its metadata is remembered as not supporting
+	// the metadata protocol so that a failure, such as a missing kube context, is not cached forever
+	UnsupportedMetadataTTL = 24 * time.Hour
+)
+
+// Metadata describes the commands of a plugin so that they can be included in the help, completion and docs of jx.
+//
+// A plugin supports the metadata protocol if invoking it with the MetadataArg argument prints the JSON
+// of its Metadata to stdout and exits successfully
+type Metadata struct {
+	// Name the name of the command
+	Name string `json:"name"`
+
+	// Aliases the aliases of the command
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Short the short description of the command
+	Short string `json:"short,omitempty"`
+
+	// Long the long description of the command
+	Long string `json:"long,omitempty"`
+
+	// Example the examples of using the command
+	Example string `json:"example,omitempty"`
+
+	// Flags the flags of the command
+	Flags []FlagMetadata `json:"flags,omitempty"`
+
+	// Subcommands the sub commands of the command
+	Subcommands []*Metadata `json:"subcommands,omitempty"`
+}
+
+// FlagMetadata describes a flag of a plugin command
+type FlagMetadata struct {
+	// Name the name of the flag without the leading dashes
+	Name string `json:"name"`
+
+	// Shorthand the optional one letter abbreviation of the flag
+	Shorthand string `json:"shorthand,omitempty"`
+
+	// Usage the description of the flag
+	Usage string `json:"usage,omitempty"`
+
+	// Type the type of the flag value such as string, bool or int
+	Type string `json:"type,omitempty"`
+
+	// Default the default value of the flag
+	Default string `json:"default,omitempty"`
+}
+
+// NewMetadata creates the metadata of a cobra command which plugins can print when invoked with the MetadataArg
+func NewMetadata(cmd *cobra.Command) *Metadata {
+	m := &Metadata{
+		Name:    cmd.Name(),
+		Aliases: cmd.Aliases,
+		Short:   cmd.Short,
+		Long:    cmd.Long,
+		Example: cmd.Example,
+	}
+	cmd.NonInheritedFlags().VisitAll(func(f *pflag.Flag) {
+		if f.Hidden || f.Name == "help" {
+			return
+		}
+		m.Flags = append(m.Flags, FlagMetadata{
+			Name:      f.Name,
+			Shorthand: f.Shorthand,
+			Usage:     f.Usage,
+			Type:      f.Value.Type(),
+			Default:   f.DefValue,
+		})
+	})
+	for _, c := range cmd.Commands() {
+		if c.IsAvailableCommand() {
+			m.Subcommands = append(m.Subcommands, NewMetadata(c))
+		}
+	}
+	return m
+}
+
+// MetadataLoader loads the metadata of plugin binaries caching the results by the hash of the binary
+type MetadataLoader struct {
+	// CacheDir the directory the metadata is cached in or empty to disable caching
+	CacheDir string
+
+	// Timeout how long a plugin is given to print its metadata
+	Timeout time.Duration
+
+	// CacheOnly only returns cached metadata without invoking the plugins such as during shell completion
+	CacheOnly bool
+
+	// UnsupportedTTL how long plugins which did not print their metadata are cached for. Defaults to UnsupportedMetadataTTL
+	UnsupportedTTL time.Duration
+
+	// Env the policies of the environment variables passed to the plugins
+	Env *config.Env
+}
+
+// metadataCacheEntry the cached metadata of a plugin binary
+type metadataCacheEntry struct {
+	// Supported false if the plugin does not support the metadata protocol
+	Supported bool      `json:"supported"`
+	Metadata  *Metadata `json:"metadata,omitempty"`
+
+	// Fetched when the plugin was invoked so that unsupported entries expire
+	Fetched time.Time `json:"fetched,omitempty"`
+}
+
+// expired returns true if the plugin should be invoked again as it did not print its metadata a while ago
+func (e *metadataCacheEntry) expired(ttl time.Duration) bool {
+	if ttl <= 0 {
+		ttl = UnsupportedMetadataTTL
+	}
+	return !e.Supported && time.Since(e.Fetched) >= ttl
+}
+
+// binaryHashEntry the cached hash of a binary so that it is only hashed again if it changes
+type binaryHashEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Hash    string    `json:"hash"`
+}
+
+// NewMetadataLoader creates a metadata loader using the cache dir in the jx home dir
+func NewMetadataLoader() *MetadataLoader {
+	l := &MetadataLoader{Timeout: DefaultMetadataTimeout}
+	env, err := loadEnv()
+	if err != nil {
+		log.Logger().Debugf("using the default plugin environment policies: %s", err.Error())
+	}
+	l.Env = env
+	dir, err := config.CacheDir()
+	if err != nil {
+		log.Logger().Debugf("not caching plugin metadata: %s", err.Error())
+		return l
+	}
+	l.CacheDir = filepath.Join(dir, "metadata")
+	return l
+}
+
+// Load returns the metadata of the given plugin binary or nil if the plugin does not support the metadata protocol
+func (l *MetadataLoader) Load(path string) (*Metadata, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve plugin binary %s: %w", path, err)
+	}
+	hash, err := l.binaryHash(path)
+	if err != nil {
+		return nil, err
+	}
+	cacheFile := ""
+	if l.CacheDir != "" {
+		cacheFile = filepath.Join(l.CacheDir, hash+".json")
+		entry := &metadataCacheEntry{}
+		if readJSONFile(cacheFile, entry) && (l.CacheOnly || !entry.expired(l.UnsupportedTTL)) {
+			return entry.Metadata, nil
+		}
+	}
+	if l.CacheOnly {
+		return nil, nil
+	}
+
+	m, err := l.invoke(path)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			// lets not cache timeouts as the machine may just be busy
+			return nil, err
+		}
+		log.Logger().Debugf("plugin %s does not support the metadata protocol: %s", path, err.Error())
+	}
+	if cacheFile != "" {
+		writeJSONFile(cacheFile, &metadataCacheEntry{Supported: m != nil, Metadata: m, Fetched: time.Now()})
+	}
+	return m, nil
+}
+
+// invoke runs the plugin with the metadata argument and parses its output
+func (l *MetadataLoader) invoke(path string) (*Metadata, error) {
+	timeout := l.Timeout
+	if timeout <= 0 {
+		timeout = DefaultMetadataTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, MetadataArg)
+	cmd.Stdout = &out
+	cmd.Stderr = io.Discard
+	cmd.Env = PluginEnv(l.Env, path, os.Environ())
+	cmd.WaitDelay = completionWaitDelay
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("timed out getting the metadata of plugin %s: %w", path, ctx.Err())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to run %s %s: %w", path, MetadataArg, err)
+	}
+	m := &Metadata{}
+	err = json.Unmarshal(out.Bytes(), m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the metadata of plugin %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// binaryHash returns the sha256 of the binary reusing the cached hash if the binary has not changed
+func (l *MetadataLoader) binaryHash(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to stat plugin binary %s: %w", path, err)
+	}
+	hashFile := ""
+	if l.CacheDir != "" {
+		hashFile = filepath.Join(l.CacheDir, "hashes", Checksum([]byte(path))+".json")
+		entry := &binaryHashEntry{}
+		if readJSONFile(hashFile, entry) && entry.Path == path && entry.Size == fi.Size() && entry.ModTime.Equal(fi.ModTime()) {
+			return entry.Hash, nil
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open plugin binary %s: %w", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", fmt.Errorf("failed to read plugin binary %s: %w", path, err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	if hashFile != "" {
+		writeJSONFile(hashFile, &binaryHashEntry{Path: path, Size: fi.Size(), ModTime: fi.ModTime(), Hash: hash})
+	}
+	return hash, nil
+}
+
+// DescribePlugins returns a copy of the plugins using the descriptions from the cached metadata of the installed
+// plugins so that no plugins are invoked to render the help
+func DescribePlugins(pluginBinDir string, list []jenkinsv1.Plugin) []jenkinsv1.Plugin {
+	installed, err := InstalledPlugins(pluginBinDir)
+	if err != nil {
+		return list
+	}
+	loader := NewMetadataLoader()
+	loader.CacheOnly = true
+	answer := make([]jenkinsv1.Plugin, 0, len(list))
+	for i := range list {
+		p := list[i]
+		info := newPluginInfo(pluginBinDir, p.Spec.Name, installed[p.Spec.Name])
+		if info.Path != "" {
+			m, err := loader.Load(info.Path)
+			if err != nil {
+				log.Logger().Debugf("failed to load metadata of plugin %s: %s", p.Spec.Name, err.Error())
+			} else if m != nil && m.Short != "" {
+				p.Spec.Description = m.Short
+			}
+		}
+		answer = append(answer, p)
+	}
+	return answer
+}
+
+// applyMetadata updates the plugin command with the descriptions, flags and sub commands of the metadata
+func applyMetadata(cmd *cobra.Command, m *Metadata, path string, args []string) {
+	if m.Short != "" {
+		cmd.Short = m.Short
+	}
+	if m.Long != "" {
+		cmd.Long = m.Long
+	}
+	if m.Example != "" {
+		cmd.Example = m.Example
+	}
+	addMetadataFlags(cmd.Flags(), m.Flags)
+	for _, sub := range m.Subcommands {
+		if sub == nil || sub.Name == "" {
+			continue
+		}
+		subArgs := append(append([]string{}, args...), sub.Name)
+		child := &cobra.Command{
+			Use:                sub.Name,
+			Aliases:            sub.Aliases,
+			DisableFlagParsing: true,
+			ValidArgsFunction: func(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+				return pluginCompletion(path, append(append([]string{}, subArgs...), args...), toComplete)
+			},
+			Run: func(_ *cobra.Command, _ []string) {},
+		}
+		applyMetadata(child, sub, path, subArgs)
+		cmd.AddCommand(child)
+	}
+}
+
+// addMetadataFlags adds the flags so that they are displayed in the help and docs of the plugin command
+func addMetadataFlags(fs *pflag.FlagSet, flags []FlagMetadata) {
+	for _, f := range flags {
+		if f.Name == "" || fs.Lookup(f.Name) != nil {
+			continue
+		}
+		shorthand := f.Shorthand
+		if len(shorthand) != 1 || fs.ShorthandLookup(shorthand) != nil {
+			shorthand = ""
+		}
+		flag := fs.VarPF(&metadataFlagValue{value: f.Default, typ: f.Type}, f.Name, shorthand, f.Usage)
+		flag.DefValue = f.Default
+		if f.Type == "bool" {
+			flag.NoOptDefVal = "true"
+		}
+	}
+}
+
+// metadataFlagValue a flag value which only describes the flag of a plugin as the plugin parses its own flags
+type metadataFlagValue struct {
+	value string
+	typ   string
+}
+
+func (v *metadataFlagValue) String() string {
+	return v.value
+}
+
+func (v *metadataFlagValue) Set(s string) error {
+	v.value = s
+	return nil
+}
+
+func (v *metadataFlagValue) Type() string {
+	if v.typ == "" {
+		return "string"
+	}
+	return v.typ
+}
+
+func readJSONFile(path string, v interface{}) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// writeJSONFile writes the cache file logging any failures as the cache is only an optimisation
+func writeJSONFile(path string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), files.DefaultDirWritePermissions)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, files.DefaultFileWritePermissions)
+	}
+	if err != nil {
+		log.Logger().Debugf("failed to write cache file %s: %s", path, err.Error())
+	}
+}
@@ -0,0 +1,195 @@
+package plugins_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createMetadataPlugin creates a plugin script which prints the given metadata and records each invocation in a log file
+func createMetadataPlugin(t *testing.T, path string, m *plugins.Metadata) string {
+	logFile := path + ".log"
+	script := "#!/bin/sh\necho \"$@\" >> " + logFile + "\n"
+	if m != nil {
+		data, err := json.Marshal(m)
+		require.NoError(t, err)
+		script += "if [ \"$1\" = \"" + plugins.MetadataArg + "\" ]; then\n  echo '" + string(data) + "'\n  exit 0\nfi\n"
+	}
+	script += "exit 1\n"
+	err := os.WriteFile(path, []byte(script), 0o700) //nolint:gosec
+	require.NoError(t, err)
+	return logFile
+}
+
+func invocations(t *testing.T, logFile string) int {
+	data, err := os.ReadFile(logFile)
+	if os.IsNotExist(err) {
+		return 0
+	}
+	require.NoError(t, err)
+	return strings.Count(string(data), "\n")
+}
+
+func newFooCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "foo",
+		Short: "Does foo things",
+	}
+	bar := &cobra.Command{
+		Use:     "bar",
+		Short:   "Creates a bar",
+		Aliases: []string{"b"},
+		Run:     func(_ *cobra.Command, _ []string) {},
+	}
+	bar.Flags().StringP("name", "n", "thingy", "the name of the bar")
+	bar.Flags().Bool("batch-mode", false, "runs in batch mode")
+	cmd.AddCommand(bar)
+	return cmd
+}
+
+func TestMetadataLoaderCachesByBinaryHash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jx-foo")
+	expected := plugins.NewMetadata(newFooCommand())
+	logFile := createMetadataPlugin(t, path, expected)
+
+	loader := &plugins.MetadataLoader{CacheDir: filepath.Join(dir, "cache")}
+	for i := 0; i < 3; i++ {
+		m, err := loader.Load(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, m)
+	}
+	assert.Equal(t, 1, invocations(t, logFile), "should only invoke the plugin once")
+
+	// changing the binary should invalidate the cache
+	expected.Short = "Does foo things better"
+	createMetadataPlugin(t, path, expected)
+	m, err := loader.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "Does foo things better", m.Short)
+	assert.Equal(t, 2, invocations(t, logFile))
+}
+
+func TestMetadataLoaderUnsupportedPlugin(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jx-old")
+	logFile := createMetadataPlugin(t, path, nil)
+
+	loader := &plugins.MetadataLoader{CacheDir: filepath.Join(dir, "cache")}
+	for i := 0; i < 2; i++ {
+		m, err := loader.Load(path)
+		require.NoError(t, err)
+		assert.Nil(t, m)
+	}
+	assert.Equal(t, 1, invocations(t, logFile), "should remember the plugin does not support metadata")
+
+	// lets try again once the failure expires in case it was only failing temporarily
+	loader.UnsupportedTTL = time.Nanosecond
+	_, err := loader.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2, invocations(t, logFile), "should invoke the plugin again once the entry expires")
+}
+
+func TestMetadataLoaderCacheOnly(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jx-foo")
+	expected := plugins.NewMetadata(newFooCommand())
+	logFile := createMetadataPlugin(t, path, expected)
+
+	loader := &plugins.MetadataLoader{CacheDir: filepath.Join(dir, "cache"), CacheOnly: true}
+	m, err := loader.Load(path)
+	require.NoError(t, err)
+	assert.Nil(t, m, "should not have any metadata until it is cached")
+	assert.Equal(t, 0, invocations(t, logFile), "should not invoke the plugin")
+
+	loader.CacheOnly = false
+	_, err = loader.Load(path)
+	require.NoError(t, err)
+
+	loader.CacheOnly = true
+	m, err = loader.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, m)
+	assert.Equal(t, 1, invocations(t, logFile))
+}
+
+func TestRegisterPluginCommandsUsesMetadata(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("JX3_HOME", home)
+	pluginBinDir := filepath.Join(home, "plugins", "bin")
+	require.NoError(t, os.MkdirAll(pluginBinDir, 0o700))
+	createMetadataPlugin(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.0.0"), plugins.NewMetadata(newFooCommand()))
+
+	root := &cobra.Command{Use: "jx"}
+	plugins.RegisterPluginCommands(root, true)
+
+	cmd, _, err := root.Find([]string{"foo", "bar"})
+	require.NoError(t, err)
+	assert.Equal(t, "bar", cmd.Name())
+	assert.Equal(t, "Creates a bar", cmd.Short)
+	assert.Equal(t, []string{"b"}, cmd.Aliases)
+	assert.Equal(t, "Does foo things", cmd.Parent().Short)
+
+	flag := cmd.Flags().Lookup("name")
+	require.NotNil(t, flag, "should have a name flag")
+	assert.Equal(t, "n", flag.Shorthand)
+	assert.Equal(t, "thingy", flag.DefValue)
+	assert.Equal(t, "string", flag.Value.Type())
+}
+
+func TestDescribePluginsUsesCachedMetadata(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("JX3_HOME", home)
+	pluginBinDir := filepath.Join(home, "plugins", "bin")
+	require.NoError(t, os.MkdirAll(pluginBinDir, 0o700))
+	p := jenkinsv1.Plugin{Spec: jenkinsv1.PluginSpec{Name: "jx-foo", Version: "1.0.0", Description: "foo plugin"}}
+	path := plugins.PluginBinary(pluginBinDir, "jx-foo", "1.0.0")
+	logFile := createMetadataPlugin(t, path, plugins.NewMetadata(newFooCommand()))
+
+	list := plugins.DescribePlugins(pluginBinDir, []jenkinsv1.Plugin{p})
+	assert.Equal(t, "foo plugin", list[0].Spec.Description, "should not have a description until the metadata is cached")
+	assert.Equal(t, 0, invocations(t, logFile), "should not invoke plugins to render the help")
+
+	_, err := plugins.NewMetadataLoader().Load(path)
+	require.NoError(t, err)
+	list = plugins.DescribePlugins(pluginBinDir, []jenkinsv1.Plugin{p})
+	assert.Equal(t, "Does foo things", list[0].Spec.Description)
+	assert.Equal(t, 1, invocations(t, logFile))
+}
+
+func TestMetadataLoaderFiltersEnv(t *testing.T) {
+	t.Setenv("JX_TEST_SECRET", "s3cret")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "jx-envtest")
+	script := "#!/bin/sh\necho \"{\\\"short\\\":\\\"secret=$JX_TEST_SECRET\\\"}\"\n"
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o700)) //nolint:gosec
+
+	loader := &plugins.MetadataLoader{}
+	m, err := loader.Load(path)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "secret=", m.Short, "should not pass the environment to unmanaged plugins by default")
+
+	loader.Env = &config.Env{Plugins: map[string]*config.EnvPolicy{"envtest": {Allow: []string{"JX_TEST_SECRET"}}}}
+	m, err = loader.Load(path)
+	require.NoError(t, err)
+	require.NotNil(t, m)
+	assert.Equal(t, "secret=s3cret", m.Short, "should use the environment policy of the plugin")
+}
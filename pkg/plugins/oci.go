@@ -0,0 +1,493 @@
+package plugins
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/jenkins-x/jx/pkg/signature"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// OCIScheme the URL scheme of plugins distributed as OCI artifacts such as oci://registry.acme.com/plugins/jx-foo:1.2.3
+	OCIScheme = "oci://"
+
+	// OCIPlatformAnnotation the optional annotation of a layer containing the platform of its binary such as linux/amd64
+	OCIPlatformAnnotation = "io.jenkins-x.plugin.platform"
+
+	// OCIUsernameEnvVar the environment variable containing the username used to authenticate with the OCI registry
+	OCIUsernameEnvVar = "JX_OCI_USERNAME"
+
+	// OCIPasswordEnvVar the environment variable containing the password or token used to authenticate with the OCI registry
+	OCIPasswordEnvVar = "JX_OCI_PASSWORD"
+
+	// OCIRegistryEnvVar the environment variable containing the host and optional port of the OCI registry such as
+	// registry.acme.com which the $JX_OCI_USERNAME and $JX_OCI_PASSWORD credentials are sent to
+	OCIRegistryEnvVar = "JX_OCI_REGISTRY"
+
+	// OCITokenHostsEnvVar the environment variable containing a comma separated list of the hosts of the token
+	// services, other than the registry itself, which the credentials are sent to such as auth.docker.io
+	OCITokenHostsEnvVar = "JX_OCI_TOKEN_HOSTS"
+
+	ociTitleAnnotation = "org.opencontainers.image.title"
+	ociManifestAccept  = "application/vnd.oci.image.index.v1+json, application/vnd.oci.image.manifest.v1+json, " +
+		"application/vnd.docker.distribution.manifest.list.v2+json, application/vnd.docker.distribution.manifest.v2+json"
+
+	// maxOCIManifestSize the maximum size of a manifest we are prepared to read
+	maxOCIManifestSize = 4 * 1024 * 1024
+
+	// maxOCIBlobSize the maximum size of a plugin archive we are prepared to download whatever the manifest says
+	maxOCIBlobSize = 512 * 1024 * 1024
+)
+
+var ociChallengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// OCIReference a reference to an artifact in an OCI registry
+type OCIReference struct {
+	// Registry the host and optional port of the registry such as registry.acme.com
+	Registry string
+
+	// Repository the repository in the registry such as plugins/jx-foo
+	Repository string
+
+	// Tag the tag of the artifact such as 1.2.3
+	Tag string
+
+	// Digest the optional digest of the manifest such as sha256:abc...
+	Digest string
+}
+
+// ociDescriptor describes content in an OCI registry
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *ociPlatform      `json:"platform,omitempty"`
+}
+
+// ociPlatform the platform of a manifest in an image index
+type ociPlatform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+}
+
+// ociManifest an OCI image index or image manifest
+type ociManifest struct {
+	MediaType string          `json:"mediaType,omitempty"`
+	Manifests []ociDescriptor `json:"manifests,omitempty"`
+	Layers    []ociDescriptor `json:"layers,omitempty"`
+}
+
+// ociClient pulls content from a repository of an OCI registry
+type ociClient struct {
+	client *http.Client
+	ref    *OCIReference
+	auth   string
+}
+
+// IsOCI returns true if the URL is a reference to an OCI artifact
+func IsOCI(u string) bool {
+	return strings.HasPrefix(u, OCIScheme)
+}
+
+// ParseOCIReference parses a reference such as oci://registry.acme.com/plugins/jx-foo:1.2.3 or
+// oci://registry.acme.com/plugins/jx-foo@sha256:abc...
+func ParseOCIReference(ref string) (*OCIReference, error) {
+	s := strings.TrimPrefix(ref, OCIScheme)
+	registry, repository, ok := strings.Cut(s, "/")
+	if !ok || registry == "" || repository == "" {
+		return nil, fmt.Errorf("invalid OCI reference %s: expected %sREGISTRY/REPOSITORY:TAG", ref, OCIScheme)
+	}
+	answer := &OCIReference{Registry: registry}
+	if name, digest, ok := strings.Cut(repository, "@"); ok {
+		repository = name
+		answer.Digest = digest
+	}
+	if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		answer.Tag = repository[i+1:]
+		repository = repository[:i]
+	}
+	answer.Repository = repository
+	if answer.Tag == "" && answer.Digest == "" {
+		return nil, fmt.Errorf("invalid OCI reference %s: missing the tag or digest", ref)
+	}
+	return answer, nil
+}
+
+// String returns the reference as an oci:// URL
+func (r *OCIReference) String() string {
+	s := OCIScheme + r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// OCIPlugin creates a plugin which is pulled from the given OCI registry and namespace such as
+// oci://registry.acme.com/plugins using the binary name as the repository and the version as the tag
+func OCIPlugin(registry, binaryName, version string) jenkinsv1.Plugin {
+	ref := strings.TrimSuffix(registry, "/") + "/" + binaryName + ":" + version
+	name := strings.TrimPrefix(binaryName, "jx-")
+	return jenkinsv1.Plugin{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: jenkinsv1.PluginSpec{
+			SubCommand:  name,
+			Binaries:    []jenkinsv1.Binary{{Goos: runtime.GOOS, Goarch: runtime.GOARCH, URL: ref}},
+			Description: name + " commands",
+			Name:        binaryName,
+			Version:     version,
+		},
+	}
+}
+
+// pullOCI pulls the layer of the OCI artifact for the current platform, verifying its digest and signature,
+// returning the data and the file name of the layer
+func (i *Installer) pullOCI(u string, onProgress func(downloaded, total int64)) ([]byte, string, error) {
+	ref, err := ParseOCIReference(u)
+	if err != nil {
+		return nil, "", err
+	}
+	c := &ociClient{client: i.Client, ref: ref}
+	if c.client == nil {
+		c.client = httphelpers.GetClient()
+	}
+
+	reference := ref.Digest
+	if reference == "" {
+		reference = ref.Tag
+	}
+	m, err := c.manifest(reference, ref.Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(m.Manifests) > 0 {
+		desc := findOCIPlatformManifest(m.Manifests)
+		if desc == nil {
+			return nil, "", fmt.Errorf("OCI artifact %s has no manifest for %s", u, Platform())
+		}
+		m, err = c.manifest(desc.Digest, desc.Digest)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	layer, sigLayer := findOCIPlatformLayer(m.Layers)
+	if layer == nil {
+		return nil, "", fmt.Errorf("OCI artifact %s has no layer for %s", u, Platform())
+	}
+	data, err := c.blob(layer, onProgress)
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = i.verifySignature(u, data, func() ([]byte, error) {
+		if sigLayer == nil {
+			return nil, fmt.Errorf("OCI artifact %s has no %s signature layer", u, signature.Suffix)
+		}
+		return c.blob(sigLayer, nil)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	name := layer.Annotations[ociTitleAnnotation]
+	if name == "" {
+		name = ref.Repository
+	}
+	return data, name, nil
+}
+
+// findOCIPlatformManifest returns the manifest of the image index for the current platform
+func findOCIPlatformManifest(manifests []ociDescriptor) *ociDescriptor {
+	for k := range manifests {
+		p := manifests[k].Platform
+		if p != nil && strings.EqualFold(p.OS, runtime.GOOS) && strings.EqualFold(p.Architecture, runtime.GOARCH) {
+			return &manifests[k]
+		}
+	}
+	return nil
+}
+
+// findOCIPlatformLayer returns the layer containing the binary for the current platform along with its detached
+// signature layer if there is one.
+//
+// The layer is either the only layer, annotated with the platform or its title contains the platform like the
+// GitHub release archives such as jx-foo-linux-amd64.tar.gz. The only layer is not used if it is annotated with
+// a different platform
+func findOCIPlatformLayer(layers []ociDescriptor) (*ociDescriptor, *ociDescriptor) {
+	var candidates []*ociDescriptor
+	for k := range layers {
+		if !strings.HasSuffix(layers[k].Annotations[ociTitleAnnotation], signature.Suffix) {
+			candidates = append(candidates, &layers[k])
+		}
+	}
+	platform := strings.ToLower(runtime.GOOS + "/" + runtime.GOARCH)
+	var layer *ociDescriptor
+	if len(candidates) == 1 {
+		layerPlatform := candidates[0].Annotations[OCIPlatformAnnotation]
+		if layerPlatform == "" || strings.EqualFold(layerPlatform, platform) {
+			layer = candidates[0]
+		}
+	} else {
+		titlePlatform := strings.ToLower(runtime.GOOS + "-" + runtime.GOARCH)
+		for _, l := range candidates {
+			if strings.EqualFold(l.Annotations[OCIPlatformAnnotation], platform) ||
+				strings.Contains(strings.ToLower(l.Annotations[ociTitleAnnotation]), titlePlatform) {
+				layer = l
+				break
+			}
+		}
+	}
+	if layer == nil {
+		return nil, nil
+	}
+
+	title := layer.Annotations[ociTitleAnnotation]
+	for k := range layers {
+		if title != "" && layers[k].Annotations[ociTitleAnnotation] == title+signature.Suffix {
+			return layer, &layers[k]
+		}
+	}
+	return layer, nil
+}
+
+// manifest fetches the manifest with the given tag or digest verifying its digest if one is expected
+func (c *ociClient) manifest(reference, expectedDigest string) (*ociManifest, error) {
+	u := c.url("manifests", reference)
+	resp, err := c.get(u, ociManifestAccept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxOCIManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	if expectedDigest != "" {
+		err = verifyOCIDigest(expectedDigest, data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify manifest %s: %w", u, err)
+		}
+	}
+	m := &ociManifest{}
+	err = json.Unmarshal(data, m)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", u, err)
+	}
+	return m, nil
+}
+
+// blob downloads the blob of the descriptor verifying its size and digest
+func (c *ociClient) blob(desc *ociDescriptor, onProgress func(downloaded, total int64)) ([]byte, error) {
+	u := c.url("blobs", desc.Digest)
+	if desc.Size < 0 || desc.Size > maxOCIBlobSize {
+		return nil, fmt.Errorf("blob %s has a size of %d bytes which is larger than the maximum of %d bytes", u, desc.Size, maxOCIBlobSize)
+	}
+	resp, err := c.get(u, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var r io.Reader = resp.Body
+	if onProgress != nil {
+		r = &progressReader{r: resp.Body, total: desc.Size, onProgress: onProgress}
+	}
+	data, err := io.ReadAll(io.LimitReader(r, desc.Size+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", u, err)
+	}
+	if int64(len(data)) != desc.Size {
+		return nil, fmt.Errorf("failed to verify blob %s: expected %d bytes but downloaded %d", u, desc.Size, len(data))
+	}
+	err = verifyOCIDigest(desc.Digest, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify blob %s: %w", u, err)
+	}
+	return data, nil
+}
+
+// get performs a GET request authenticating with the registry if it challenges the request
+func (c *ociClient) get(u, accept string) (*http.Response, error) {
+	resp, err := c.do(u, accept)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.auth == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		c.auth, err = c.authenticate(challenge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate with OCI registry %s: %w", c.ref.Registry, err)
+		}
+		resp, err = c.do(u, accept)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: status %s", u, resp.Status)
+	}
+	return resp, nil
+}
+
+func (c *ociClient) do(u, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request for %s: %w", u, err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", u, err)
+	}
+	return resp, nil
+}
+
+// credentials returns the $JX_OCI_USERNAME and $JX_OCI_PASSWORD credentials if they are for the registry
+// in $JX_OCI_REGISTRY so that they are never sent to any other registry
+func (c *ociClient) credentials() (string, string) {
+	username := os.Getenv(OCIUsernameEnvVar)
+	if username == "" {
+		return "", ""
+	}
+	if !strings.EqualFold(os.Getenv(OCIRegistryEnvVar), c.ref.Registry) {
+		log.Logger().Debugf("not using the $%s credentials for registry %s as $%s is not set to it", OCIUsernameEnvVar, c.ref.Registry, OCIRegistryEnvVar)
+		return "", ""
+	}
+	return username, os.Getenv(OCIPasswordEnvVar)
+}
+
+// isTokenHost returns true if the credentials of the registry can be sent to the token service on the host
+func (c *ociClient) isTokenHost(host string) bool {
+	if strings.EqualFold(host, c.ref.Registry) {
+		return true
+	}
+	for _, h := range strings.Split(os.Getenv(OCITokenHostsEnvVar), ",") {
+		if h = strings.TrimSpace(h); h != "" && strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns the Authorization header for the challenge of the registry using the
+// $JX_OCI_USERNAME and $JX_OCI_PASSWORD credentials if they are set for the registry
+func (c *ociClient) authenticate(challenge string) (string, error) {
+	username, password := c.credentials()
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if username == "" {
+			return "", fmt.Errorf("the registry %s requires credentials so please set $%s, $%s and $%s", c.ref.Registry, OCIUsernameEnvVar, OCIPasswordEnvVar, OCIRegistryEnvVar)
+		}
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	values := map[string]string{}
+	for _, m := range ociChallengeParamPattern.FindAllStringSubmatch(params, -1) {
+		values[m[1]] = m[2]
+	}
+	realm := values["realm"]
+	if realm == "" {
+		return "", errors.New("the bearer challenge has no realm")
+	}
+	query := url.Values{}
+	if values["service"] != "" {
+		query.Set("service", values["service"])
+	}
+	scope := values["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.Repository + ":pull"
+	}
+	query.Set("scope", scope)
+
+	req, err := http.NewRequest(http.MethodGet, realm+"?"+query.Encode(), http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request for %s: %w", realm, err)
+	}
+	if username != "" {
+		if !c.isTokenHost(req.URL.Host) {
+			return "", fmt.Errorf("refusing to send the credentials of registry %s to the token service %s on another host. Add %s to $%s if it is trusted", c.ref.Registry, realm, req.URL.Host, OCITokenHostsEnvVar)
+		}
+		req.SetBasicAuth(username, password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request token from %s: %w", realm, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to request token from %s: status %s", realm, resp.Status)
+	}
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&token)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse token from %s: %w", realm, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return "", fmt.Errorf("no token returned from %s", realm)
+	}
+	return "Bearer " + token.Token, nil
+}
+
+// url returns the URL of the registry API for the manifests or blobs of the repository.
+//
+// Registries on the local host are accessed via plain HTTP like docker does so that they can be used in tests
+func (c *ociClient) url(kind, reference string) string {
+	scheme := "https"
+	host := c.ref.Registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" || net.ParseIP(host).IsLoopback() {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, c.ref.Registry, c.ref.Repository, kind, reference)
+}
+
+// verifyOCIDigest verifies the data has the given sha256 digest
+func verifyOCIDigest(digest string, data []byte) error {
+	algorithm, expected, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" {
+		return fmt.Errorf("unsupported digest %s, only sha256 digests are supported", digest)
+	}
+	sum := sha256.Sum256(data)
+	actual := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf("digest mismatch: expected sha256:%s but got sha256:%s", expected, actual)
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package plugins_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/jenkins-x/jx/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ociRegistry a fake OCI registry which requires a bearer token
+type ociRegistry struct {
+	blobs     map[string][]byte
+	manifests map[string][]byte
+
+	// realm the token service or empty to use the token service of the registry
+	realm string
+}
+
+func (r *ociRegistry) addBlob(data []byte) map[string]interface{} {
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	r.blobs[digest] = data
+	return map[string]interface{}{"digest": digest, "size": len(data)}
+}
+
+func (r *ociRegistry) addManifest(t *testing.T, tag string, m interface{}) map[string]interface{} {
+	data, err := json.Marshal(m)
+	require.NoError(t, err)
+	desc := r.addBlob(data)
+	r.manifests[desc["digest"].(string)] = data
+	if tag != "" {
+		r.manifests[tag] = data
+	}
+	return desc
+}
+
+func (r *ociRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		w.Write([]byte(`{"token": "secret"}`)) //nolint:errcheck
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		realm := r.realm
+		if realm == "" {
+			realm = "http://" + req.Host + "/token"
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+realm+`",service="test"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	path := strings.TrimPrefix(req.URL.Path, "/v2/plugins/jx-foo/")
+	kind, reference, _ := strings.Cut(path, "/")
+	var data []byte
+	switch kind {
+	case "manifests":
+		data = r.manifests[reference]
+	case "blobs":
+		data = r.blobs[reference]
+	}
+	if data == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Write(data) //nolint:errcheck
+}
+
+func TestInstallOCIPlugin(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	archive := createArchive(t, "jx-foo", []byte("#!/bin/sh\necho foo\n"))
+	digest := sha256.Sum256(archive)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	registry := &ociRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	title := "jx-foo-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.gz"
+	layer := registry.addBlob(archive)
+	layer["annotations"] = map[string]string{"org.opencontainers.image.title": title}
+	sigLayer := registry.addBlob(sig)
+	sigLayer["annotations"] = map[string]string{"org.opencontainers.image.title": title + signature.Suffix}
+	other := registry.addBlob([]byte("other"))
+	other["annotations"] = map[string]string{"org.opencontainers.image.title": "jx-foo-plan9-mips.tar.gz"}
+	manifest := registry.addManifest(t, "", map[string]interface{}{"layers": []interface{}{other, layer, sigLayer}})
+	manifest["platform"] = map[string]string{"os": runtime.GOOS, "architecture": runtime.GOARCH}
+	registry.addManifest(t, "1.0.0", map[string]interface{}{"manifests": []interface{}{manifest}})
+
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	ref := plugins.OCIScheme + strings.TrimPrefix(server.URL, "http://") + "/plugins"
+	plugin := plugins.OCIPlugin(ref, "jx-foo", "1.0.0")
+	installer := &plugins.Installer{
+		Lock:     &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+		Verifier: &signature.Verifier{Keys: []*ecdsa.PublicKey{&key.PublicKey}},
+		Client:   server.Client(),
+	}
+
+	pluginBinDir := t.TempDir()
+	path, err := installer.EnsurePluginInstalled(plugin, pluginBinDir)
+	require.NoError(t, err)
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.0.0"), path)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "#!/bin/sh\necho foo\n", string(data))
+	assert.Equal(t, plugins.Checksum(archive), installer.Lock.Checksum("jx-foo", "1.0.0", plugins.Platform()))
+
+	// lets tamper with the layer
+	registry.blobs[layer["digest"].(string)] = append(archive, 0)
+	_, err = installer.EnsurePluginInstalled(plugins.OCIPlugin(ref, "jx-foo", "1.0.0"), t.TempDir())
+	require.Error(t, err, "should fail to install a layer with the wrong digest")
+	assert.Contains(t, err.Error(), "failed to verify blob")
+}
+
+func TestInstallOCIPluginSingleLayer(t *testing.T) {
+	t.Parallel()
+
+	archive := createArchive(t, "jx-foo", []byte("#!/bin/sh\necho foo\n"))
+	install := func(annotations map[string]string, size int64) error {
+		registry := &ociRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+		layer := registry.addBlob(archive)
+		layer["annotations"] = annotations
+		if size != 0 {
+			layer["size"] = size
+		}
+		registry.addManifest(t, "1.0.0", map[string]interface{}{"layers": []interface{}{layer}})
+		server := httptest.NewServer(registry)
+		defer server.Close()
+
+		ref := plugins.OCIScheme + strings.TrimPrefix(server.URL, "http://") + "/plugins"
+		installer := &plugins.Installer{
+			Lock:       &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+			SkipVerify: true,
+			Client:     server.Client(),
+		}
+		_, err := installer.EnsurePluginInstalled(plugins.OCIPlugin(ref, "jx-foo", "1.0.0"), t.TempDir())
+		return err
+	}
+
+	assert.NoError(t, install(nil, 0), "should use the only layer")
+	assert.NoError(t, install(map[string]string{plugins.OCIPlatformAnnotation: runtime.GOOS + "/" + runtime.GOARCH}, 0))
+
+	err := install(map[string]string{plugins.OCIPlatformAnnotation: "plan9/mips"}, 0)
+	require.Error(t, err, "should not use the only layer if it is for another platform")
+	assert.Contains(t, err.Error(), "has no layer for")
+
+	err = install(nil, 1<<40)
+	require.Error(t, err, "should not trust the size of a huge blob")
+	assert.Contains(t, err.Error(), "larger than the maximum")
+}
+
+// tokenService a fake token service which only issues tokens to the given credentials recording any credentials it sees
+type tokenService struct {
+	username, password string
+	seen               atomic.Value
+}
+
+func (s *tokenService) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	username, password, ok := req.BasicAuth()
+	if ok {
+		s.seen.Store(username + ":" + password)
+	}
+	if username != s.username || password != s.password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	w.Write([]byte(`{"token": "secret"}`)) //nolint:errcheck
+}
+
+func TestInstallOCIPluginCredentials(t *testing.T) {
+	archive := createArchive(t, "jx-foo", []byte("#!/bin/sh\necho foo\n"))
+	registry := &ociRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	registry.addManifest(t, "1.0.0", map[string]interface{}{"layers": []interface{}{registry.addBlob(archive)}})
+	tokens := &tokenService{username: "me", password: "pwd"}
+	tokenServer := httptest.NewServer(tokens)
+	defer tokenServer.Close()
+	registry.realm = tokenServer.URL + "/token"
+	server := httptest.NewServer(registry)
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	install := func() error {
+		installer := &plugins.Installer{
+			Lock:       &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+			SkipVerify: true,
+			Client:     server.Client(),
+		}
+		_, err := installer.EnsurePluginInstalled(plugins.OCIPlugin(plugins.OCIScheme+host+"/plugins", "jx-foo", "1.0.0"), t.TempDir())
+		return err
+	}
+
+	t.Setenv(plugins.OCIUsernameEnvVar, "me")
+	t.Setenv(plugins.OCIPasswordEnvVar, "pwd")
+	t.Setenv(plugins.OCIRegistryEnvVar, "registry.acme.com")
+	t.Setenv(plugins.OCITokenHostsEnvVar, "")
+	require.Error(t, install(), "should not use the credentials of another registry")
+	assert.Nil(t, tokens.seen.Load(), "should not send the credentials of another registry")
+
+	t.Setenv(plugins.OCIRegistryEnvVar, host)
+	err := install()
+	require.Error(t, err, "should not send the credentials to a token service on another host")
+	assert.Contains(t, err.Error(), "refusing to send the credentials")
+	assert.Nil(t, tokens.seen.Load())
+
+	t.Setenv(plugins.OCITokenHostsEnvVar, "auth.acme.com, "+strings.TrimPrefix(tokenServer.URL, "http://"))
+	require.NoError(t, install())
+	assert.Equal(t, "me:pwd", tokens.seen.Load())
+}
+
+func TestParseOCIReference(t *testing.T) {
+	t.Parallel()
+
+	ref, err := plugins.ParseOCIReference("oci://localhost:5000/org/jx-foo:1.2.3")
+	require.NoError(t, err)
+	assert.Equal(t, &plugins.OCIReference{Registry: "localhost:5000", Repository: "org/jx-foo", Tag: "1.2.3"}, ref)
+	assert.Equal(t, "oci://localhost:5000/org/jx-foo:1.2.3", ref.String())
+
+	ref, err = plugins.ParseOCIReference("oci://registry.acme.com/jx-foo@sha256:abc")
+	require.NoError(t, err)
+	assert.Equal(t, &plugins.OCIReference{Registry: "registry.acme.com", Repository: "jx-foo", Digest: "sha256:abc"}, ref)
+
+	_, err = plugins.ParseOCIReference("oci://registry.acme.com/jx-foo")
+	assert.Error(t, err, "should require a tag or digest")
+	_, err = plugins.ParseOCIReference("oci://registry.acme.com")
+	assert.Error(t, err, "should require a repository")
+}
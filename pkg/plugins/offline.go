@@ -0,0 +1,133 @@
+package plugins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+
+	"github.com/blang/semver"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// OfflineEnvVar the environment variable which stops plugins being downloaded if set to true. It is passed
+	// to plugins so that they can avoid using the network too
+	OfflineEnvVar = "JX_OFFLINE"
+
+	// CompatibleMinor installed versions with the same major and minor version can be used instead of a pinned version
+	CompatibleMinor = "minor"
+
+	// CompatibleMajor installed versions with the same major version can be used instead of a pinned version
+	CompatibleMajor = "major"
+
+	// CompatibleAny any installed version can be used instead of a pinned version
+	CompatibleAny = "any"
+)
+
+// OfflineError is returned when a plugin is not installed and cannot be downloaded as jx is offline
+type OfflineError struct {
+	// Binary the name of the plugin binary
+	Binary string
+
+	// Version the version of the plugin or empty if any version would do
+	Version string
+}
+
+func (e *OfflineError) Error() string {
+	if e.Version == "" {
+		return fmt.Sprintf("plugin %s is not installed and cannot be downloaded as jx is offline", e.Binary)
+	}
+	return fmt.Sprintf("plugin %s version %s is not installed and cannot be downloaded as jx is offline", e.Binary, e.Version)
+}
+
+// IsOffline returns true if plugins must not be downloaded as the $JX_OFFLINE environment variable is true
+func IsOffline() bool {
+	return strings.EqualFold(os.Getenv(OfflineEnvVar), "true")
+}
+
+// IsNetworkError returns true if the error was caused by the network being unavailable such as failing to
+// resolve or connect to a host, a timeout or exceeding the GitHub API rate limit
+func IsNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr), errors.As(err, &opErr), IsRateLimitError(err):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ENETUNREACH) || errors.Is(err, syscall.EHOSTUNREACH)
+}
+
+// IsCompatible returns true if the installed version can be used instead of the pinned version
+// for the given compatibility of minor, major or any
+func IsCompatible(installed, pinned, compatible string) bool {
+	if compatible == CompatibleAny {
+		return true
+	}
+	iv, err := semver.Parse(installed)
+	if err != nil {
+		return false
+	}
+	pv, err := semver.Parse(pinned)
+	if err != nil {
+		return false
+	}
+	if compatible == CompatibleMajor {
+		return iv.Major == pv.Major
+	}
+	return iv.Major == pv.Major && iv.Minor == pv.Minor
+}
+
+// FindCompatible returns the newest installed version of the plugin in the search path which is compatible with
+// the pinned version or nil if there is none
+func (s *SearchPath) FindCompatible(binaryName, version string) *PluginLocation {
+	var answer *PluginLocation
+	var newest semver.Version
+	for _, l := range s.Locations(binaryName) {
+		if l.Version == "" || !IsCompatible(l.Version, version, s.Compatible) {
+			continue
+		}
+		v, err := semver.Parse(l.Version)
+		if err != nil {
+			if answer == nil {
+				answer = l
+			}
+			continue
+		}
+		if answer == nil || v.GT(newest) {
+			answer, newest = l, v
+		}
+	}
+	return answer
+}
+
+// fallback returns the newest compatible installed version of the plugin warning that it is used instead of the
+// pinned version which could not be installed
+func (s *SearchPath) fallback(binaryName, version string, installErr error) (string, error) {
+	l := s.FindCompatible(binaryName, version)
+	if l == nil {
+		var err error = &OfflineError{Binary: binaryName, Version: version}
+		if installErr != nil {
+			err = fmt.Errorf("failed to download plugin %s version %s and there is no compatible version installed: %w", binaryName, version, installErr)
+		}
+		return "", err
+	}
+	reason := "jx is offline"
+	if installErr != nil {
+		reason = "it could not be downloaded: " + installErr.Error()
+	}
+	log.Logger().Warnf("%s using plugin %s version %s instead of version %s as %s", termcolor.ColorWarning("WARNING:"), binaryName,
+		termcolor.ColorWarning(l.Version), termcolor.ColorInfo(version), reason)
+	return l.Path, nil
+}
@@ -0,0 +1,92 @@
+package plugins_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsCompatible(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		installed, pinned, compatible string
+		expected                      bool
+	}{
+		{"1.2.0", "1.2.9", "", true},
+		{"1.2.0", "1.2.9", plugins.CompatibleMinor, true},
+		{"1.3.0", "1.2.9", plugins.CompatibleMinor, false},
+		{"1.3.0", "1.2.9", plugins.CompatibleMajor, true},
+		{"2.0.0", "1.2.9", plugins.CompatibleMajor, false},
+		{"2.0.0", "1.2.9", plugins.CompatibleAny, true},
+		{"notsemver", "1.2.9", plugins.CompatibleMajor, false},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.expected, plugins.IsCompatible(tc.installed, tc.pinned, tc.compatible), "for %#v", tc)
+	}
+}
+
+func TestOfflineFallback(t *testing.T) {
+	t.Parallel()
+
+	pluginBinDir := t.TempDir()
+	for _, v := range []string{"1.2.0", "1.2.5", "1.3.0", "2.0.0"} {
+		writeExecutable(t, plugins.PluginBinary(pluginBinDir, "jx-foo", v))
+	}
+	s := &plugins.SearchPath{Dirs: []string{pluginBinDir}, PluginBinDir: pluginBinDir, Offline: true}
+
+	testCases := map[string]string{
+		"":                      "1.2.5",
+		plugins.CompatibleMajor: "1.3.0",
+		plugins.CompatibleAny:   "2.0.0",
+	}
+	for compatible, expected := range testCases {
+		s.Compatible = compatible
+		path, err := s.EnsurePluginInstalled(createPlugin("jx-foo", "1.2.9", "https://example.com/jx-foo.tar.gz"))
+		require.NoError(t, err, "for compatible %s", compatible)
+		assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-foo", expected), path, "for compatible %s", compatible)
+	}
+
+	// the pinned version is used if it is installed
+	path, err := s.EnsurePluginInstalled(createPlugin("jx-foo", "1.2.0", "https://example.com/jx-foo.tar.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.2.0"), path)
+
+	s.Compatible = ""
+	_, err = s.EnsurePluginInstalled(createPlugin("jx-foo", "3.0.0", "https://example.com/jx-foo.tar.gz"))
+	var offlineErr *plugins.OfflineError
+	require.True(t, errors.As(err, &offlineErr), "should fail with an offline error but got %v", err)
+	assert.Equal(t, "jx-foo", offlineErr.Binary)
+}
+
+func TestFallbackOnNetworkError(t *testing.T) {
+	t.Setenv("JX3_HOME", t.TempDir())
+	t.Setenv(plugins.OfflineEnvVar, "")
+
+	pluginBinDir := t.TempDir()
+	writeExecutable(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.2.0"))
+	s := &plugins.SearchPath{Dirs: []string{pluginBinDir}, PluginBinDir: pluginBinDir}
+
+	// nothing listens on the discard port so the download fails to connect
+	path, err := s.EnsurePluginInstalled(createPlugin("jx-foo", "1.2.1", "http://127.0.0.1:9/jx-foo.tar.gz"))
+	require.NoError(t, err)
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-foo", "1.2.0"), path)
+	assert.NoFileExists(t, filepath.Join(pluginBinDir, "jx-foo-1.2.1"))
+}
+
+func TestIsNetworkError(t *testing.T) {
+	t.Parallel()
+
+	dnsErr := &net.DNSError{Err: "no such host", Name: "github.com"}
+	assert.True(t, plugins.IsNetworkError(fmt.Errorf("failed to download: %w", dnsErr)))
+	assert.True(t, plugins.IsNetworkError(&net.OpError{Op: "dial", Err: errors.New("connection refused")}))
+	assert.True(t, plugins.IsNetworkError(&plugins.RateLimitError{URL: "https://api.github.com"}))
+	assert.False(t, plugins.IsNetworkError(errors.New("checksum mismatch")))
+	assert.False(t, plugins.IsNetworkError(nil))
+}
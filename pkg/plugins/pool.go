@@ -0,0 +1,71 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultInstallConcurrency the default number of plugins installed concurrently
+const DefaultInstallConcurrency = 4
+
+// InstallTask a plugin to install
+type InstallTask struct {
+	// Name the name of the plugin
+	Name string
+
+	// Install installs the plugin returning the path to the binary
+	Install func() (string, error)
+}
+
+// InstallResult the result of an InstallTask
+type InstallResult struct {
+	// Name the name of the plugin
+	Name string
+
+	// Path the path to the installed binary
+	Path string
+
+	// Err the error if the install failed
+	Err error
+}
+
+// InstallAll runs the install tasks using at most the given number of concurrent workers.
+//
+// All the tasks are run even if some fail. The results are returned in the same order as the tasks along with
+// an error aggregating all of the failures
+func InstallAll(tasks []InstallTask, concurrency int) ([]InstallResult, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]InstallResult, len(tasks))
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+	for w := 0; w < concurrency && w < len(tasks); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range indexes {
+				task := tasks[idx]
+				path, err := task.Install()
+				results[idx] = InstallResult{Name: task.Name, Path: path, Err: err}
+			}
+		}()
+	}
+	for idx := range tasks {
+		indexes <- idx
+	}
+	close(indexes)
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("plugin %s: %w", r.Name, r.Err))
+		}
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("failed to install %d of %d plugins:\n%w", len(errs), len(tasks), errors.Join(errs...))
+	}
+	return results, nil
+}
@@ -0,0 +1,56 @@
+package plugins_test
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstallAll(t *testing.T) {
+	t.Parallel()
+
+	var running, maxRunning int32
+	var tasks []plugins.InstallTask
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("jx-plugin%d", i)
+		tasks = append(tasks, plugins.InstallTask{
+			Name: name,
+			Install: func() (string, error) {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				if name == "jx-plugin3" || name == "jx-plugin7" {
+					return "", errors.New("boom")
+				}
+				return "/bin/" + name, nil
+			},
+		})
+	}
+
+	results, err := plugins.InstallAll(tasks, 3)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to install 2 of 10 plugins")
+	assert.Contains(t, err.Error(), "plugin jx-plugin3: boom")
+	assert.Contains(t, err.Error(), "plugin jx-plugin7: boom")
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(3), "should not exceed the concurrency")
+
+	require.Len(t, results, 10)
+	for i, r := range results {
+		assert.Equal(t, tasks[i].Name, r.Name, "results should be in task order")
+		if r.Err == nil {
+			assert.Equal(t, "/bin/"+r.Name, r.Path)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package plugins
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"golang.org/x/term"
+)
+
+const progressRedrawInterval = 100 * time.Millisecond
+
+// Progress receives updates about the plugins being downloaded and installed
+type Progress interface {
+	// Start is called when the download of a plugin version starts
+	Start(name, version string)
+
+	// Update is called as the plugin is downloaded with the number of bytes downloaded and the total size
+	// which is zero if the size is not known
+	Update(name string, downloaded, total int64)
+
+	// Done is called when the plugin has been installed or failed to install
+	Done(name string, err error)
+}
+
+// NewProgress creates a progress reporter which redraws a progress line per plugin if the output is a terminal
+// or logs a line when each plugin starts and completes otherwise
+func NewProgress(out *os.File) Progress {
+	if term.IsTerminal(int(out.Fd())) {
+		return NewTerminalProgress(out)
+	}
+	return NewLogProgress()
+}
+
+// progressEntry the progress of a single plugin
+type progressEntry struct {
+	name       string
+	version    string
+	started    time.Time
+	downloaded int64
+	total      int64
+	done       bool
+	err        error
+}
+
+func (e *progressEntry) rate() string {
+	elapsed := time.Since(e.started).Seconds()
+	if elapsed <= 0 {
+		return ""
+	}
+	return FormatBytes(int64(float64(e.downloaded)/elapsed)) + "/s"
+}
+
+func (e *progressEntry) String() string {
+	size := FormatBytes(e.downloaded)
+	if e.total > 0 {
+		size += " / " + FormatBytes(e.total)
+	}
+	status := e.rate()
+	switch {
+	case e.err != nil:
+		status = termcolor.ColorError("failed")
+	case e.done:
+		status = termcolor.ColorInfo("done")
+	}
+	return fmt.Sprintf("%-20s %-10s %22s  %s", e.name, e.version, size, status)
+}
+
+// terminalProgress redraws a progress line for each plugin
+type terminalProgress struct {
+	out      io.Writer
+	mu       sync.Mutex
+	entries  []*progressEntry
+	byName   map[string]*progressEntry
+	lines    int
+	lastDraw time.Time
+}
+
+// NewTerminalProgress creates a progress reporter which redraws a progress line per plugin on the terminal
+func NewTerminalProgress(out io.Writer) Progress {
+	return &terminalProgress{
+		out:    out,
+		byName: map[string]*progressEntry{},
+	}
+}
+
+func (p *terminalProgress) Start(name, version string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := &progressEntry{name: name, version: version, started: time.Now()}
+	p.entries = append(p.entries, e)
+	p.byName[name] = e
+	p.draw(true)
+}
+
+func (p *terminalProgress) Update(name string, downloaded, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.byName[name]
+	if e == nil {
+		return
+	}
+	e.downloaded = downloaded
+	e.total = total
+	p.draw(false)
+}
+
+func (p *terminalProgress) Done(name string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.byName[name]
+	if e == nil {
+		return
+	}
+	e.done = true
+	e.err = err
+	p.draw(true)
+}
+
+// draw redraws all the progress lines, throttling redraws unless forced
+func (p *terminalProgress) draw(force bool) {
+	if !force && time.Since(p.lastDraw) < progressRedrawInterval {
+		return
+	}
+	p.lastDraw = time.Now()
+	if p.lines > 0 {
+		// move the cursor back to the first progress line
+		fmt.Fprintf(p.out, "\x1b[%dA", p.lines)
+	}
+	for _, e := range p.entries {
+		fmt.Fprintf(p.out, "\r\x1b[K%s\n", e.String())
+	}
+	p.lines = len(p.entries)
+}
+
+// logProgress logs a line when each plugin starts and completes
+type logProgress struct {
+	mu      sync.Mutex
+	entries map[string]*progressEntry
+}
+
+// NewLogProgress creates a progress reporter which logs a line when each plugin starts and completes
+func NewLogProgress() Progress {
+	return &logProgress{entries: map[string]*progressEntry{}}
+}
+
+func (p *logProgress) Start(name, version string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[name] = &progressEntry{name: name, version: version, started: time.Now()}
+	log.Logger().Infof("downloading plugin %s version %s", termcolor.ColorInfo(name), termcolor.ColorInfo(version))
+}
+
+func (p *logProgress) Update(name string, downloaded, total int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.entries[name]; e != nil {
+		e.downloaded = downloaded
+		e.total = total
+	}
+}
+
+func (p *logProgress) Done(name string, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.entries[name]
+	if e == nil || err != nil {
+		// failures are reported by the caller
+		return
+	}
+	log.Logger().Infof("installed plugin %s version %s (%s at %s in %s)", termcolor.ColorInfo(name), termcolor.ColorInfo(e.version),
+		FormatBytes(e.downloaded), e.rate(), time.Since(e.started).Round(time.Millisecond))
+}
+
+// progressReader reports the number of bytes read to a progress reporter
+type progressReader struct {
+	r          io.Reader
+	downloaded int64
+	total      int64
+	onProgress func(downloaded, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.downloaded += int64(n)
+	r.onProgress(r.downloaded, r.total)
+	return n, err
+}
@@ -0,0 +1,31 @@
+package plugins_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTerminalProgress(t *testing.T) {
+	t.Parallel()
+
+	out := &bytes.Buffer{}
+	p := plugins.NewTerminalProgress(out)
+	p.Start("jx-foo", "1.0.0")
+	p.Start("jx-bar", "2.0.0")
+	p.Update("jx-foo", 512, 2048)
+	p.Done("jx-foo", nil)
+	p.Done("jx-bar", errors.New("boom"))
+
+	text := out.String()
+	assert.Contains(t, text, "\x1b[2A", "should redraw over the previous lines")
+
+	// the last redraw has the final state of every plugin
+	last := text[strings.LastIndex(text, "\x1b[2A"):]
+	assert.Regexp(t, `jx-foo\s+1\.0\.0\s+512 B / 2\.0 KB\s+done`, last)
+	assert.Regexp(t, `jx-bar\s+2\.0\.0\s+0 B\s+failed`, last)
+}
@@ -0,0 +1,131 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"sigs.k8s.io/yaml"
+)
+
+// ProjectPluginsFile the file in a repository which pins the versions of plugins used in that repository
+var ProjectPluginsFile = filepath.Join(".jx", "plugins.yaml")
+
+// ProjectPlugins the plugin versions pinned by a repository
+type ProjectPlugins struct {
+	// Plugins the pinned plugins
+	Plugins []ProjectPlugin `json:"plugins,omitempty"`
+
+	// Path the file the plugins were loaded from
+	Path string `json:"-"`
+}
+
+// ProjectPlugin a plugin version pinned by a repository
+type ProjectPlugin struct {
+	// Name the name of the plugin such as preview or jx-preview
+	Name string `json:"name"`
+
+	// Version the version of the plugin to use
+	Version string `json:"version"`
+
+	// Owner the GitHub owner of the plugin repository. Defaults to jenkins-x-plugins which is the only owner
+	// allowed for managed plugins
+	Owner string `json:"owner,omitempty"`
+
+	// Registry the OCI registry and namespace the plugin is pulled from instead of GitHub such as
+	// oci://registry.acme.com/plugins. The binary name is used as the repository and the version as the tag.
+	// Managed plugins cannot be pulled from a registry
+	Registry string `json:"registry,omitempty"`
+}
+
+// FindProjectPlugins walks up from the given dir, or the current dir if empty, looking for the project plugins
+// file returning nil if there is none
+func FindProjectPlugins(dir string) (*ProjectPlugins, error) {
+	var err error
+	if dir == "" {
+		dir, err = os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the current directory: %w", err)
+		}
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the absolute path of %s: %w", dir, err)
+	}
+	for {
+		path := filepath.Join(dir, ProjectPluginsFile)
+		exists, err := files.FileExists(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if file exists %s: %w", path, err)
+		}
+		if exists {
+			return LoadProjectPlugins(path)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectPlugins loads the project plugins file
+func LoadProjectPlugins(path string) (*ProjectPlugins, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	p := &ProjectPlugins{}
+	err = yaml.Unmarshal(data, p)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for i := range p.Plugins {
+		pp := &p.Plugins[i]
+		if pp.Name == "" || pp.Version == "" {
+			return nil, fmt.Errorf("plugin %d in %s must have a name and version", i+1, path)
+		}
+		if !ValidBinaryName(BinaryName(pp.Name)) {
+			return nil, fmt.Errorf("plugin %s in %s has an invalid name", pp.Name, path)
+		}
+		err = ValidateVersion(pp.Version)
+		if err != nil {
+			return nil, fmt.Errorf("plugin %s in %s has an invalid version: %w", pp.Name, path, err)
+		}
+		// a cloned repository must not be able to replace the binaries of the managed plugins
+		managed := PluginMap[BinaryName(pp.Name)] != nil
+		if managed && ((pp.Owner != "" && pp.Owner != jenkinsxPluginsOrganisation) || pp.Registry != "") {
+			return nil, fmt.Errorf("plugin %s in %s is managed by jx so only its version can be pinned, not its owner or registry", pp.Name, path)
+		}
+	}
+	p.Path = path
+	return p, nil
+}
+
+// Plugin returns the plugin pinned for the given binary name such as jx-preview or nil if it is not pinned
+func (p *ProjectPlugins) Plugin(binaryName string) *jenkinsv1.Plugin {
+	if p == nil {
+		return nil
+	}
+	for i := range p.Plugins {
+		pp := &p.Plugins[i]
+		if BinaryName(pp.Name) != binaryName {
+			continue
+		}
+		if pp.Registry != "" {
+			plugin := OCIPlugin(pp.Registry, binaryName, strings.TrimPrefix(pp.Version, "v"))
+			return &plugin
+		}
+		owner := pp.Owner
+		if owner == "" {
+			owner = jenkinsxPluginsOrganisation
+		}
+		plugin := extensions.CreateJXPlugin(owner, strings.TrimPrefix(binaryName, "jx-"), strings.TrimPrefix(pp.Version, "v"))
+		return &plugin
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindProjectPlugins(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	path := filepath.Join(root, plugins.ProjectPluginsFile)
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	err := os.WriteFile(path, []byte(`plugins:
+- name: preview
+  version: v0.6.0
+- name: jx-foo
+  version: 1.2.3
+  owner: acme
+- name: bar
+  version: 2.0.0
+  registry: oci://registry.acme.com/plugins/
+`), 0o600)
+	require.NoError(t, err)
+
+	subDir := filepath.Join(root, "charts", "myapp")
+	require.NoError(t, os.MkdirAll(subDir, 0o700))
+
+	pp, err := plugins.FindProjectPlugins(subDir)
+	require.NoError(t, err)
+	require.NotNil(t, pp, "should find the file in a parent dir")
+	assert.Equal(t, path, pp.Path)
+
+	preview := pp.Plugin("jx-preview")
+	require.NotNil(t, preview)
+	assert.Equal(t, "jx-preview", preview.Spec.Name)
+	assert.Equal(t, "0.6.0", preview.Spec.Version)
+
+	foo := pp.Plugin("jx-foo")
+	require.NotNil(t, foo)
+	u, err := plugins.PluginURL(&foo.Spec)
+	require.NoError(t, err)
+	assert.Contains(t, u, "https://github.com/acme/jx-foo/releases/download/v1.2.3/")
+
+	bar := pp.Plugin("jx-bar")
+	require.NotNil(t, bar)
+	u, err = plugins.PluginURL(&bar.Spec)
+	require.NoError(t, err)
+	assert.Equal(t, "oci://registry.acme.com/plugins/jx-bar:2.0.0", u)
+
+	assert.Nil(t, pp.Plugin("jx-gitops"), "should not pin other plugins")
+}
+
+func TestFindProjectPluginsNotFound(t *testing.T) {
+	t.Parallel()
+
+	pp, err := plugins.FindProjectPlugins(t.TempDir())
+	require.NoError(t, err)
+	assert.Nil(t, pp)
+	assert.Nil(t, pp.Plugin("jx-preview"), "should be safe to use a missing file")
+}
+
+func TestLoadProjectPluginsInvalid(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "plugins.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("plugins:\n- name: preview\n"), 0o600))
+	_, err := plugins.LoadProjectPlugins(path)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "must have a name and version")
+}
+
+func TestLoadProjectPluginsInvalidVersions(t *testing.T) {
+	t.Parallel()
+
+	for _, version := range []string{"1/../../../tmp/evil", `1.0.0\\evil`, "1.0.0..evil", "latest"} {
+		path := filepath.Join(t.TempDir(), "plugins.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("plugins:\n- name: mytool\n  version: '"+version+"'\n"), 0o600))
+		_, err := plugins.LoadProjectPlugins(path)
+		require.Error(t, err, "should reject version %s", version)
+		assert.Contains(t, err.Error(), "invalid version")
+	}
+
+	path := filepath.Join(t.TempDir(), "plugins.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("plugins:\n- name: ../evil\n  version: 1.0.0\n"), 0o600))
+	_, err := plugins.LoadProjectPlugins(path)
+	require.Error(t, err, "should reject names which are not valid file names")
+	assert.Contains(t, err.Error(), "invalid name")
+
+	path = filepath.Join(t.TempDir(), "plugins.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("plugins:\n- name: mytool\n  version: v1.2.3-rc.1\n"), 0o600))
+	_, err = plugins.LoadProjectPlugins(path)
+	assert.NoError(t, err)
+}
+
+func TestLoadProjectPluginsManagedOverrides(t *testing.T) {
+	t.Parallel()
+
+	for _, override := range []string{"owner: evil", "registry: oci://registry.evil.com/plugins"} {
+		path := filepath.Join(t.TempDir(), "plugins.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("plugins:\n- name: gitops\n  version: 1.0.0\n  "+override+"\n"), 0o600))
+		_, err := plugins.LoadProjectPlugins(path)
+		require.Error(t, err, "should not allow %s for a managed plugin", override)
+		assert.Contains(t, err.Error(), "only its version can be pinned")
+	}
+
+	path := filepath.Join(t.TempDir(), "plugins.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("plugins:\n- name: gitops\n  version: 1.0.0\n  owner: jenkins-x-plugins\n"), 0o600))
+	_, err := plugins.LoadProjectPlugins(path)
+	assert.NoError(t, err)
+}
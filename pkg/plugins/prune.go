@@ -0,0 +1,104 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+// DefaultPruneKeep the default number of versions of unmanaged plugins kept when pruning
+const DefaultPruneKeep = 1
+
+// PrunablePlugin a plugin binary which can be removed from the plugin bin dir
+type PrunablePlugin struct {
+	// Binary the name of the plugin binary such as jx-gitops
+	Binary string `json:"binary"`
+
+	// Version the version of the plugin
+	Version string `json:"version"`
+
+	// Path the path of the binary
+	Path string `json:"path"`
+
+	// Size the size of the binary in bytes
+	Size int64 `json:"size"`
+}
+
+// FindPrunablePlugins returns the plugin binaries which can be removed from the plugin bin dir keeping
+// the pinned version of managed plugins and the given number of the most recent versions of unmanaged plugins
+func FindPrunablePlugins(pluginBinDir string, keep int) ([]PrunablePlugin, error) {
+	installed, err := InstalledPlugins(pluginBinDir)
+	if err != nil {
+		return nil, err
+	}
+	var answer []PrunablePlugin
+	for i := range Plugins {
+		spec := &Plugins[i].Spec
+		for _, v := range installed[spec.Name] {
+			if v != spec.Version {
+				answer = append(answer, PrunablePlugin{Binary: spec.Name, Version: v})
+			}
+		}
+	}
+	for _, name := range sortedKeys(installed) {
+		if PluginMap[name] != nil {
+			continue
+		}
+		versions := installed[name]
+		if len(versions) > keep {
+			for _, v := range versions[keep:] {
+				answer = append(answer, PrunablePlugin{Binary: name, Version: v})
+			}
+		}
+	}
+	for i := range answer {
+		p := &answer[i]
+		p.Path = PluginBinary(pluginBinDir, p.Binary, p.Version)
+		info, err := os.Stat(p.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat %s: %w", p.Path, err)
+		}
+		p.Size = info.Size()
+	}
+	return answer, nil
+}
+
+// PrunePlugins removes the given plugin binaries returning the number of bytes reclaimed
+func PrunePlugins(prunable []PrunablePlugin) (int64, error) {
+	var size int64
+	for _, p := range prunable {
+		err := os.Remove(p.Path)
+		if err != nil {
+			return size, fmt.Errorf("failed to remove %s: %w", p.Path, err)
+		}
+		log.Logger().Infof("removed plugin %s version %s", termcolor.ColorInfo(p.Binary), termcolor.ColorInfo(p.Version))
+		size += p.Size
+	}
+	return size, nil
+}
+
+// FormatBytes formats a number of bytes for humans such as 12.3 MB
+func FormatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
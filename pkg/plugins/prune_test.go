@@ -0,0 +1,51 @@
+package plugins_test
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrunePlugins(t *testing.T) {
+	t.Parallel()
+
+	dir := createPluginBinaries(t,
+		"jx-gitops-0.0.1",
+		"jx-gitops-"+plugins.GitOpsVersion,
+		"jx-gitops-99.0.0",
+		"jx-doesnotexist-1.0.0",
+		"jx-doesnotexist-1.1.0",
+		"jx-doesnotexist-2.0.0",
+	)
+
+	prunable, err := plugins.FindPrunablePlugins(dir, 2)
+	require.NoError(t, err)
+
+	var names []string
+	for _, p := range prunable {
+		names = append(names, p.Binary+"-"+p.Version)
+		assert.Positive(t, p.Size, "size of %s", p.Path)
+	}
+	assert.ElementsMatch(t, []string{"jx-gitops-99.0.0", "jx-gitops-0.0.1", "jx-doesnotexist-1.0.0"}, names)
+
+	size, err := plugins.PrunePlugins(prunable)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3*len("#!/bin/sh\n")), size)
+
+	installed, err := plugins.InstalledPlugins(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"jx-gitops":       {plugins.GitOpsVersion},
+		"jx-doesnotexist": {"2.0.0", "1.1.0"},
+	}, installed)
+}
+
+func TestFormatBytes(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "12 B", plugins.FormatBytes(12))
+	assert.Equal(t, "1.5 KB", plugins.FormatBytes(1536))
+	assert.Equal(t, "2.0 GB", plugins.FormatBytes(2*1024*1024*1024))
+}
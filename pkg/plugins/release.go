@@ -0,0 +1,248 @@
+package plugins
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// GitHubTokenEnvVar the environment variable containing the token used to authenticate GitHub API requests
+	GitHubTokenEnvVar = "GITHUB_TOKEN"
+
+	// ReleaseCacheTTLEnvVar the environment variable used to override how long latest releases are cached for
+	ReleaseCacheTTLEnvVar = "JX_RELEASE_CACHE_TTL"
+
+	// DefaultReleaseCacheTTL how long the latest release of a plugin is cached before it is revalidated
+	DefaultReleaseCacheTTL = time.Hour
+)
+
+// RateLimitError is returned when the GitHub API rate limit has been exceeded
+type RateLimitError struct {
+	// URL the URL which was rate limited
+	URL string
+
+	// Reset when the rate limit resets or the zero time if it is not known
+	Reset time.Time
+}
+
+func (e *RateLimitError) Error() string {
+	msg := fmt.Sprintf("GitHub API rate limit exceeded for %s", e.URL)
+	if !e.Reset.IsZero() {
+		msg += fmt.Sprintf(", it resets at %s (in %s)", e.Reset.Local().Format(time.Kitchen), time.Until(e.Reset).Round(time.Second))
+	}
+	return msg + fmt.Sprintf(". Set $%s to use the higher authenticated rate limit", GitHubTokenEnvVar)
+}
+
+type githubRelease struct {
+	TagName string `json:"tag_name"`
+}
+
+// releaseCacheEntry the cached latest release of a repository
+type releaseCacheEntry struct {
+	URL     string    `json:"url"`
+	TagName string    `json:"tagName"`
+	ETag    string    `json:"etag,omitempty"`
+	Fetched time.Time `json:"fetched"`
+}
+
+// ReleaseCacheTTL returns how long latest releases are cached for
+func ReleaseCacheTTL() time.Duration {
+	v := os.Getenv(ReleaseCacheTTLEnvVar)
+	if v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil {
+			return d
+		}
+		log.Logger().Warnf("ignoring invalid $%s value %s: %s", ReleaseCacheTTLEnvVar, v, err.Error())
+	}
+	return DefaultReleaseCacheTTL
+}
+
+// LatestRelease returns the tag name of the latest release of the given GitHub repository.
+//
+// Results are cached in the cache dir for the cache TTL after which they are revalidated using the ETag of the
+// response so that lookups rarely count against the GitHub API rate limit
+func (i *Installer) LatestRelease(owner, repo string) (string, error) {
+	u := i.Mirror.APIURL("repos/" + owner + "/" + repo + "/releases/latest")
+	cacheFile := i.releaseCacheFile(u)
+	entry := loadReleaseCache(cacheFile)
+	if entry != nil && time.Since(entry.Fetched) < i.CacheTTL {
+		return entry.TagName, nil
+	}
+	if IsOffline() {
+		if entry != nil {
+			return entry.TagName, nil
+		}
+		return "", &OfflineError{Binary: repo}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return "", fmt.Errorf("failed to create http request for %s: %w", u, err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if entry != nil && entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+	token := i.githubToken(req.URL)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := i.Client
+	if client == nil {
+		client = httphelpers.GetClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to GET endpoint %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && entry != nil:
+		entry.Fetched = time.Now()
+		saveReleaseCache(cacheFile, entry)
+		return entry.TagName, nil
+
+	case isRateLimited(resp):
+		rateErr := newRateLimitError(u, resp)
+		if entry != nil {
+			log.Logger().Warnf("%s, using the cached release %s", rateErr.Error(), entry.TagName)
+			return entry.TagName, nil
+		}
+		return "", rateErr
+
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("failed to GET endpoint %s with status %s", u, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response from %s: %w", u, err)
+	}
+	release := &githubRelease{}
+	err = json.Unmarshal(body, release)
+	if err != nil {
+		return "", fmt.Errorf("failed to unmarshal release from %s: %w", u, err)
+	}
+	if release.TagName == "" {
+		return "", fmt.Errorf("can't find latest release of %s/%s: %s", owner, repo, body)
+	}
+	saveReleaseCache(cacheFile, &releaseCacheEntry{
+		URL:     u,
+		TagName: release.TagName,
+		ETag:    resp.Header.Get("ETag"),
+		Fetched: time.Now(),
+	})
+	return release.TagName, nil
+}
+
+// githubToken returns the token used to authenticate requests to the given API URL if it is GitHub or the
+// configured GitHub Enterprise host
+func (i *Installer) githubToken(u *url.URL) string {
+	if !i.Mirror.IsTokenHost(u.Host) {
+		log.Logger().Debugf("not sending the GitHub token to %s as it is not api.github.com or the configured GitHub Enterprise host", u.Host)
+		return ""
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.Token == "" && !i.tokenResolved {
+		i.tokenResolved = true
+		i.Token = gitCredentialPassword(strings.TrimPrefix(u.Host, "api."))
+	}
+	return i.Token
+}
+
+// gitCredentialPassword returns the password stored in the git credential helpers for the given host if any
+func gitCredentialPassword(host string) string {
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=https\nhost=%s\n\n", host))
+	// never prompt the user for credentials
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "SSH_ASKPASS=")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	if err != nil {
+		log.Logger().Debugf("failed to find git credentials for %s: %s", host, err.Error())
+		return ""
+	}
+	for _, line := range strings.Split(out.String(), "\n") {
+		if v, ok := strings.CutPrefix(line, "password="); ok {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+func isRateLimited(resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	return resp.StatusCode == http.StatusForbidden &&
+		(resp.Header.Get("X-RateLimit-Remaining") == "0" || resp.Header.Get("Retry-After") != "")
+}
+
+func newRateLimitError(u string, resp *http.Response) *RateLimitError {
+	e := &RateLimitError{URL: u}
+	if v := resp.Header.Get("X-RateLimit-Reset"); v != "" {
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			e.Reset = time.Unix(secs, 0)
+		}
+	} else if v := resp.Header.Get("Retry-After"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil {
+			e.Reset = time.Now().Add(time.Duration(secs) * time.Second)
+		}
+	}
+	return e
+}
+
+// IsRateLimitError returns true if the error was caused by exceeding the GitHub API rate limit
+func IsRateLimitError(err error) bool {
+	var rateErr *RateLimitError
+	return errors.As(err, &rateErr)
+}
+
+// releaseCacheFile returns the file the latest release of the given API URL is cached in or empty if caching is disabled
+func (i *Installer) releaseCacheFile(u string) string {
+	if i.CacheDir == "" {
+		return ""
+	}
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(parsed.Path, "/"), "/releases/latest")
+	return filepath.Join(i.CacheDir, "releases", parsed.Host, filepath.FromSlash(name)+".json")
+}
+
+func loadReleaseCache(path string) *releaseCacheEntry {
+	if path == "" {
+		return nil
+	}
+	entry := &releaseCacheEntry{}
+	if !readJSONFile(path, entry) || entry.TagName == "" {
+		return nil
+	}
+	return entry
+}
+
+func saveReleaseCache(path string, entry *releaseCacheEntry) {
+	if path != "" {
+		writeJSONFile(path, entry)
+	}
+}
@@ -0,0 +1,125 @@
+package plugins_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const latestReleasePath = "/repos/jenkins-x-plugins/jx-foo/releases/latest"
+
+func newReleaseInstaller(t *testing.T, server *httptest.Server, ttl time.Duration) *plugins.Installer {
+	return &plugins.Installer{
+		Mirror:   &config.Mirror{GitHubAPIURL: server.URL, GitHubEnterpriseHost: server.Listener.Addr().String()},
+		Client:   server.Client(),
+		Token:    "my-token",
+		CacheDir: t.TempDir(),
+		CacheTTL: ttl,
+	}
+}
+
+func TestLatestReleaseIsCached(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		assert.Equal(t, latestReleasePath, r.URL.Path)
+		assert.Equal(t, "Bearer my-token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"tag_name": "v1.2.3"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	installer := newReleaseInstaller(t, server, time.Hour)
+	for i := 0; i < 3; i++ {
+		tagName, err := installer.LatestRelease("jenkins-x-plugins", "jx-foo")
+		require.NoError(t, err)
+		assert.Equal(t, "v1.2.3", tagName)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests), "should only query GitHub once within the TTL")
+}
+
+func TestLatestReleaseRevalidatesWithETag(t *testing.T) {
+	t.Parallel()
+
+	var notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"abc"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte(`{"tag_name": "v1.2.3"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	installer := newReleaseInstaller(t, server, time.Nanosecond)
+	for i := 0; i < 3; i++ {
+		tagName, err := installer.LatestRelease("jenkins-x-plugins", "jx-foo")
+		require.NoError(t, err)
+		assert.Equal(t, "v1.2.3", tagName)
+	}
+	assert.Equal(t, int32(2), atomic.LoadInt32(&notModified), "should revalidate the expired cache entry")
+}
+
+func TestLatestReleaseRateLimited(t *testing.T) {
+	t.Parallel()
+
+	reset := time.Now().Add(10 * time.Minute).Truncate(time.Second)
+	var limited atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if limited.Load() {
+			w.Header().Set("X-RateLimit-Remaining", "0")
+			w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"tag_name": "v1.2.3"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	limited.Store(true)
+	installer := newReleaseInstaller(t, server, time.Nanosecond)
+	_, err := installer.LatestRelease("jenkins-x-plugins", "jx-foo")
+	require.Error(t, err)
+	assert.True(t, plugins.IsRateLimitError(err))
+	rateErr := &plugins.RateLimitError{}
+	require.ErrorAs(t, err, &rateErr)
+	assert.Equal(t, reset, rateErr.Reset)
+	assert.Contains(t, err.Error(), "rate limit exceeded")
+	assert.Contains(t, err.Error(), plugins.GitHubTokenEnvVar)
+
+	// once a release is cached it is used while rate limited
+	limited.Store(false)
+	_, err = installer.LatestRelease("jenkins-x-plugins", "jx-foo")
+	require.NoError(t, err)
+	limited.Store(true)
+	tagName, err := installer.LatestRelease("jenkins-x-plugins", "jx-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", tagName)
+}
+
+func TestLatestReleaseDoesNotSendTokenToMirror(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Authorization"), "should not send the token to a mirror")
+		w.Write([]byte(`{"tag_name": "v1.2.3"}`)) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	installer := newReleaseInstaller(t, server, time.Hour)
+	installer.Mirror.GitHubEnterpriseHost = ""
+	tagName, err := installer.LatestRelease("jenkins-x-plugins", "jx-foo")
+	require.NoError(t, err)
+	assert.Equal(t, "v1.2.3", tagName)
+}
@@ -0,0 +1,199 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+)
+
+const (
+	// DefaultUnknownPluginTTL how long a name which is not found in the plugin indexes is remembered so that
+	// repeatedly mistyping a command does not load the indexes again
+	DefaultUnknownPluginTTL = time.Hour
+
+	// unknownPluginsFileName the file in the cache dir recording the names which are not known plugins
+	unknownPluginsFileName = "unknown-plugins.json"
+)
+
+// UnknownPluginError is returned when a name is neither an installed plugin nor a plugin which is known to be
+// installable from the managed plugins, the repositories plugins were installed from or the plugin indexes
+type UnknownPluginError struct {
+	// Binary the name of the plugin binary such as jx-foo
+	Binary string
+}
+
+func (e *UnknownPluginError) Error() string {
+	return fmt.Sprintf("unknown plugin %s: it is not installed, managed by jx or in a plugin index. Use 'jx plugin search' to find plugins", e.Binary)
+}
+
+// IsUnknownPluginError returns true if the error was caused by a name which is not a known plugin
+func IsUnknownPluginError(err error) bool {
+	var unknownErr *UnknownPluginError
+	return errors.As(err, &unknownErr)
+}
+
+// Resolver finds the binaries of plugins, only installing plugins which are known so that mistyped commands
+// never cause downloads or GitHub API calls
+type Resolver struct {
+	// SearchPath the directories plugins are looked up in
+	SearchPath *SearchPath
+
+	// Sources the repositories plugins were installed from
+	Sources *Sources
+
+	// IndexLoader the loader of the configured plugin indexes or nil if there are none
+	IndexLoader *IndexLoader
+
+	// CacheDir the directory names which are not in the plugin indexes are cached in or empty to disable caching
+	CacheDir string
+
+	// UnknownTTL how long names which are not in the plugin indexes are cached for
+	UnknownTTL time.Duration
+
+	// Installer installs plugins. Defaults to a new installer
+	Installer *Installer
+
+	indexPlugins []*IndexPlugin
+	indexLoaded  bool
+}
+
+// NewResolver creates a resolver of the plugins in the search path using the configured plugin indexes and the
+// repositories plugins were installed from
+func NewResolver(searchPath *SearchPath) *Resolver {
+	r := &Resolver{
+		SearchPath: searchPath,
+		UnknownTTL: DefaultUnknownPluginTTL,
+	}
+	var err error
+	r.Sources, err = LoadSources()
+	if err != nil {
+		log.Logger().Debugf("failed to load plugin sources: %s", err.Error())
+	}
+	loader, err := NewIndexLoader()
+	if err != nil {
+		log.Logger().Debugf("failed to load plugin indexes: %s", err.Error())
+	} else if len(loader.Indexes) > 0 {
+		r.IndexLoader = loader
+		r.CacheDir = loader.CacheDir
+	}
+	return r
+}
+
+// Resolve returns the binary of the plugin with the highest precedence in the search path, installing the plugin
+// only if it is known
+func (r *Resolver) Resolve(binaryName string) (string, error) {
+	if l := r.SearchPath.Find(binaryName); l != nil {
+		return l.Path, nil
+	}
+	return r.Install(binaryName)
+}
+
+// ResolveLocal returns the binary of the plugin with the highest precedence in the search path, installing the
+// plugin only if it is a managed plugin or was installed from a repository so that the plugin indexes are not loaded
+func (r *Resolver) ResolveLocal(binaryName string) (string, error) {
+	if l := r.SearchPath.Find(binaryName); l != nil {
+		return l.Path, nil
+	}
+	return r.installLocal(binaryName)
+}
+
+// Install installs the plugin if it is a managed plugin, was installed from a repository or is in a plugin index.
+// Otherwise an UnknownPluginError is returned without using the network
+func (r *Resolver) Install(binaryName string) (string, error) {
+	path, err := r.installLocal(binaryName)
+	if !IsUnknownPluginError(err) {
+		return path, err
+	}
+	return r.InstallFromIndex(binaryName)
+}
+
+// InstallFromIndex installs the plugin if it is in a plugin index. Otherwise an UnknownPluginError is returned
+func (r *Resolver) InstallFromIndex(binaryName string) (string, error) {
+	if p := r.findIndexPlugin(binaryName); p != nil {
+		log.Logger().Infof("installing plugin %s version %s from plugin index %s", binaryName, p.Plugin.Spec.Version, p.Index)
+		return r.SearchPath.EnsurePluginInstalled(p.Plugin)
+	}
+	return "", &UnknownPluginError{Binary: binaryName}
+}
+
+// installLocal installs the plugin if it is a managed plugin or was installed from a repository
+func (r *Resolver) installLocal(binaryName string) (string, error) {
+	if p := PluginMap[binaryName]; p != nil {
+		return r.SearchPath.EnsurePluginInstalled(*p)
+	}
+	if src := r.Sources.Find(binaryName); src != nil {
+		if IsOffline() {
+			return "", &OfflineError{Binary: binaryName}
+		}
+		installer, err := r.installer()
+		if err != nil {
+			return "", err
+		}
+		return installer.InstallFromSource(r.SearchPath.PluginBinDir, src, "")
+	}
+	return "", &UnknownPluginError{Binary: binaryName}
+}
+
+// findIndexPlugin returns the plugin in the configured plugin indexes remembering names which are not found
+func (r *Resolver) findIndexPlugin(binaryName string) *IndexPlugin {
+	if r.IndexLoader == nil {
+		return nil
+	}
+	unknown := r.loadUnknown()
+	if fetched, ok := unknown[binaryName]; ok && time.Since(fetched) < r.UnknownTTL {
+		return nil
+	}
+	if !r.indexLoaded {
+		r.indexLoaded = true
+		r.indexPlugins = r.IndexLoader.Load()
+	}
+	for _, p := range r.indexPlugins {
+		if p.Index != ManagedIndexName && p.Plugin.Spec.Name == binaryName {
+			return p
+		}
+	}
+
+	// lets forget expired names so the cache does not grow forever
+	for name, fetched := range unknown {
+		if time.Since(fetched) >= r.UnknownTTL {
+			delete(unknown, name)
+		}
+	}
+	unknown[binaryName] = time.Now()
+	if r.CacheDir != "" {
+		writeJSONFile(filepath.Join(r.CacheDir, unknownPluginsFileName), unknown)
+	}
+	return nil
+}
+
+func (r *Resolver) loadUnknown() map[string]time.Time {
+	unknown := map[string]time.Time{}
+	if r.CacheDir != "" {
+		readJSONFile(filepath.Join(r.CacheDir, unknownPluginsFileName), &unknown)
+	}
+	return unknown
+}
+
+func (r *Resolver) installer() (*Installer, error) {
+	if r.Installer == nil {
+		installer, err := NewInstaller()
+		if err != nil {
+			return nil, err
+		}
+		r.Installer = installer
+	}
+	return r.Installer, nil
+}
+
+// ForgetUnknownPlugins removes the cached names which were not found in the plugin indexes such as when the
+// indexes are refreshed
+func ForgetUnknownPlugins(cacheDir string) {
+	err := os.Remove(filepath.Join(cacheDir, unknownPluginsFileName))
+	if err != nil && !os.IsNotExist(err) {
+		log.Logger().Debugf("failed to remove the cached unknown plugins: %s", err.Error())
+	}
+}
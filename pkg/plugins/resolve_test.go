@@ -0,0 +1,75 @@
+package plugins_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolverUnknownPlugin(t *testing.T) {
+	t.Parallel()
+
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		fmt.Fprint(w, testIndex)
+	}))
+	defer server.Close()
+
+	cacheDir := t.TempDir()
+	newResolver := func() *plugins.Resolver {
+		return &plugins.Resolver{
+			SearchPath: plugins.NewSearchPath(t.TempDir()),
+			IndexLoader: &plugins.IndexLoader{
+				Indexes:  []config.PluginIndex{{Name: "platform", URL: server.URL + "/plugins.yaml"}},
+				CacheDir: cacheDir,
+				Client:   server.Client(),
+			},
+			CacheDir:   cacheDir,
+			UnknownTTL: time.Hour,
+		}
+	}
+
+	r := newResolver()
+	_, err := r.Resolve("jx-doesnotexist")
+	require.Error(t, err)
+	assert.True(t, plugins.IsUnknownPluginError(err), "should be an unknown plugin error but was %v", err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// the indexes are only loaded once per resolver
+	_, err = r.Resolve("jx-doesnotexist-either")
+	assert.True(t, plugins.IsUnknownPluginError(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// unknown names are remembered so the indexes are not loaded again
+	_, err = newResolver().Resolve("jx-doesnotexist")
+	assert.True(t, plugins.IsUnknownPluginError(err))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+
+	// refreshing the indexes forgets the unknown names
+	l := newResolver().IndexLoader
+	l.Refresh = true
+	l.Load()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	_, err = newResolver().Resolve("jx-doesnotexist")
+	assert.True(t, plugins.IsUnknownPluginError(err))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestResolverWithoutIndexes(t *testing.T) {
+	t.Parallel()
+
+	r := &plugins.Resolver{SearchPath: plugins.NewSearchPath(t.TempDir())}
+	_, err := r.Resolve("jx-doesnotexist")
+	require.Error(t, err)
+	assert.True(t, plugins.IsUnknownPluginError(err))
+	assert.Contains(t, err.Error(), "jx plugin search")
+}
@@ -0,0 +1,256 @@
+package plugins
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+)
+
+// PluginPathEnvVar the environment variable containing the directories plugins are looked up in, in order of
+// precedence, separated by the OS path list separator such as /opt/jx/plugins:/team/plugins:$HOME/.jx3/plugins/bin:$PATH
+//
+// If it is not set plugins are looked up on the PATH and then in the plugin bin dir. Like the PATH every directory
+// is trusted: binaries found in them are not verified against the plugin lock or signatures
+const PluginPathEnvVar = "JX_PLUGIN_PATH"
+
+// SearchPath the directories plugins are looked up in, in order of precedence.
+//
+// Each directory can contain unversioned binaries such as jx-foo and versioned binaries such as jx-foo-1.2.3
+// as installed in the plugin bin dir. Within a directory an unversioned binary takes precedence over the
+// newest version. When defaulting to the PATH only the exact pinned versions are found in the PATH dirs
+type SearchPath struct {
+	// Dirs the directories in order of precedence
+	Dirs []string
+
+	// PluginBinDir the directory plugins are installed into which is always searched
+	PluginBinDir string
+
+	// Offline never downloads plugins, using compatible installed versions instead of pinned versions
+	Offline bool
+
+	// Compatible which installed versions can be used instead of a pinned version which cannot be downloaded.
+	// Defaults to the same major and minor version
+	Compatible string
+
+	// Versioned true if every directory may contain versioned binaries as when using $JX_PLUGIN_PATH. Otherwise
+	// only the plugin bin dir is scanned for versions so that finding a plugin only stats one file in each PATH dir
+	Versioned bool
+}
+
+// PluginLocation a binary of a plugin found in the search path
+type PluginLocation struct {
+	// Dir the directory of the search path containing the binary
+	Dir string `json:"dir"`
+
+	// Path the path of the binary
+	Path string `json:"path"`
+
+	// Version the version of the binary or empty if it is unversioned such as a local build
+	Version string `json:"version,omitempty"`
+}
+
+// PluginConflict a plugin with binaries in more than one location of the search path
+type PluginConflict struct {
+	// Binary the name of the plugin binary such as jx-gitops
+	Binary string `json:"binary"`
+
+	// Managed true if the plugin version is pinned by this version of jx
+	Managed bool `json:"managed"`
+
+	// Used the binary used when invoking the plugin or nil if the pinned version of a managed plugin is not installed
+	Used *PluginLocation `json:"used,omitempty"`
+
+	// Shadowed the other binaries of the plugin which are ignored
+	Shadowed []*PluginLocation `json:"shadowed"`
+}
+
+// NewSearchPath creates the search path from the $JX_PLUGIN_PATH environment variable, defaulting to the PATH
+// followed by the plugin bin dir. It is offline if the $JX_OFFLINE environment variable is true
+func NewSearchPath(pluginBinDir string) *SearchPath {
+	value := os.Getenv(PluginPathEnvVar)
+	s := &SearchPath{PluginBinDir: pluginBinDir, Offline: IsOffline(), Versioned: value != ""}
+	if value == "" {
+		value = os.Getenv("PATH")
+	}
+	for _, dir := range append(filepath.SplitList(value), pluginBinDir) {
+		if dir == "" {
+			continue
+		}
+		dir = filepath.Clean(dir)
+		if !s.contains(dir) {
+			s.Dirs = append(s.Dirs, dir)
+		}
+	}
+	return s
+}
+
+// Locations returns the binaries of the plugin in every directory of the search path in order of precedence.
+//
+// Every directory is scanned for versioned binaries so it should only be used to report on the plugins rather
+// than when invoking them
+func (s *SearchPath) Locations(binaryName string) []*PluginLocation {
+	var answer []*PluginLocation
+	for _, dir := range s.Dirs {
+		answer = append(answer, dirLocations(dir, binaryName, true)...)
+	}
+	return answer
+}
+
+// Find returns the binary of the plugin with the highest precedence or nil if there is none.
+//
+// Only the exact unversioned binary is looked for in each directory unless the directory can contain
+// versioned binaries
+func (s *SearchPath) Find(binaryName string) *PluginLocation {
+	for _, dir := range s.Dirs {
+		locations := dirLocations(dir, binaryName, s.Versioned || dir == s.PluginBinDir)
+		if len(locations) > 0 {
+			return locations[0]
+		}
+	}
+	return nil
+}
+
+// FindVersion returns the binary of the given version of the plugin with the highest precedence or nil if there is none
+func (s *SearchPath) FindVersion(binaryName, version string) *PluginLocation {
+	for _, dir := range s.Dirs {
+		path := PluginBinary(dir, binaryName, version)
+		info, err := os.Stat(path)
+		if err == nil && !info.IsDir() {
+			return &PluginLocation{Dir: dir, Path: path, Version: version}
+		}
+	}
+	return nil
+}
+
+// EnsurePluginInstalled returns the binary of the plugin version from the search path such as a shared read only
+// directory, installing it into the plugin bin dir if it is not found. Binaries in the search path are trusted and
+// only downloaded archives are verified against the plugin lock and signatures.
+//
+// If offline or the download fails due to a network error the newest compatible installed version is used instead
+func (s *SearchPath) EnsurePluginInstalled(plugin jenkinsv1.Plugin) (string, error) {
+	spec := &plugin.Spec
+	if l := s.FindVersion(spec.Name, spec.Version); l != nil {
+		return l.Path, nil
+	}
+	if s.Offline {
+		return s.fallback(spec.Name, spec.Version, nil)
+	}
+	path, err := EnsurePluginInstalled(plugin, s.PluginBinDir)
+	if err != nil && IsNetworkError(err) {
+		return s.fallback(spec.Name, spec.Version, err)
+	}
+	return path, err
+}
+
+// Conflicts returns the plugins which have binaries in more than one directory of the search path or which
+// have both unversioned and versioned binaries, sorted by binary name
+func (s *SearchPath) Conflicts() ([]*PluginConflict, error) {
+	names := map[string]bool{}
+	for _, dir := range s.Dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			// lets ignore missing or unreadable directories like the shell does
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if res := installedPluginPattern.FindStringSubmatch(name); len(res) > 2 {
+				names[res[1]] = true
+			} else if strings.HasPrefix(name, "jx-") {
+				name = strings.TrimSuffix(name, ".exe")
+				if findExecutable(dir, name) != "" {
+					names[name] = true
+				}
+			}
+		}
+	}
+
+	var answer []*PluginConflict
+	for binaryName := range names {
+		locations := s.Locations(binaryName)
+		if !conflicting(locations) {
+			continue
+		}
+		c := &PluginConflict{Binary: binaryName}
+		if p := PluginMap[binaryName]; p != nil {
+			c.Managed = true
+			c.Used = s.FindVersion(binaryName, p.Spec.Version)
+		} else {
+			c.Used = s.Find(binaryName)
+		}
+		for _, l := range locations {
+			if c.Used == nil || l.Path != c.Used.Path {
+				c.Shadowed = append(c.Shadowed, l)
+			}
+		}
+		answer = append(answer, c)
+	}
+	sort.Slice(answer, func(i, j int) bool {
+		return answer[i].Binary < answer[j].Binary
+	})
+	return answer, nil
+}
+
+func (s *SearchPath) contains(dir string) bool {
+	for _, d := range s.Dirs {
+		if d == dir {
+			return true
+		}
+	}
+	return false
+}
+
+// conflicting returns true if the locations are in different directories or mix unversioned and versioned binaries
+func conflicting(locations []*PluginLocation) bool {
+	if len(locations) < 2 {
+		return false
+	}
+	for _, l := range locations[1:] {
+		if l.Dir != locations[0].Dir || (l.Version == "") != (locations[0].Version == "") {
+			return true
+		}
+	}
+	return false
+}
+
+// dirLocations returns the unversioned binary of the plugin in the directory followed by its versions, newest first,
+// if the directory is scanned for versions
+func dirLocations(dir, binaryName string, versioned bool) []*PluginLocation {
+	var answer []*PluginLocation
+	path := findExecutable(dir, binaryName)
+	if path != "" {
+		answer = append(answer, &PluginLocation{Dir: dir, Path: path})
+	}
+	if !versioned {
+		return answer
+	}
+	versions, err := InstalledVersions(dir, binaryName)
+	if err != nil {
+		// lets ignore missing or unreadable directories like the shell does
+		return answer
+	}
+	for _, v := range versions {
+		answer = append(answer, &PluginLocation{Dir: dir, Path: PluginBinary(dir, binaryName, v), Version: v})
+	}
+	return answer
+}
+
+// findExecutable returns the path of the unversioned executable binary in the directory or an empty string
+func findExecutable(dir, binaryName string) string {
+	path := filepath.Join(dir, binaryName)
+	if runtime.GOOS == "windows" {
+		path += ".exe"
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return ""
+	}
+	if runtime.GOOS != "windows" && info.Mode()&executableFileMode == 0 {
+		return ""
+	}
+	return path
+}
@@ -0,0 +1,95 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeExecutable(t *testing.T, path string) {
+	err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o700) //nolint:gosec
+	require.NoError(t, err, "failed to create %s", path)
+}
+
+func TestSearchPath(t *testing.T) {
+	systemDir := t.TempDir()
+	teamDir := t.TempDir()
+	pluginBinDir := t.TempDir()
+	t.Setenv(plugins.PluginPathEnvVar, strings.Join([]string{systemDir, teamDir, "", systemDir}, string(os.PathListSeparator)))
+
+	writeExecutable(t, filepath.Join(systemDir, "jx-foo-1.0.0"))
+	writeExecutable(t, filepath.Join(teamDir, "jx-foo"))
+	writeExecutable(t, filepath.Join(pluginBinDir, "jx-foo-2.0.0"))
+	writeExecutable(t, filepath.Join(pluginBinDir, "jx-bar-1.0.0"))
+	writeExecutable(t, filepath.Join(pluginBinDir, "jx-bar-1.1.0"))
+	err := os.WriteFile(filepath.Join(teamDir, "jx-notexecutable"), []byte("#!/bin/sh\n"), 0o600)
+	require.NoError(t, err)
+
+	s := plugins.NewSearchPath(pluginBinDir)
+	assert.Equal(t, []string{systemDir, teamDir, pluginBinDir}, s.Dirs, "should always include the plugin bin dir once")
+
+	l := s.Find("jx-foo")
+	require.NotNil(t, l)
+	assert.Equal(t, filepath.Join(systemDir, "jx-foo-1.0.0"), l.Path, "should use the directory with the highest precedence")
+
+	l = s.FindVersion("jx-foo", "2.0.0")
+	require.NotNil(t, l)
+	assert.Equal(t, pluginBinDir, l.Dir)
+	assert.Nil(t, s.FindVersion("jx-foo", "3.0.0"))
+	assert.Nil(t, s.Find("jx-notexecutable"))
+
+	conflicts, err := s.Conflicts()
+	require.NoError(t, err)
+	require.Len(t, conflicts, 1, "multiple versions in the same directory should not conflict")
+	c := conflicts[0]
+	assert.Equal(t, "jx-foo", c.Binary)
+	assert.Equal(t, filepath.Join(systemDir, "jx-foo-1.0.0"), c.Used.Path)
+	var shadowed []string
+	for _, l := range c.Shadowed {
+		shadowed = append(shadowed, l.Path)
+	}
+	assert.Equal(t, []string{filepath.Join(teamDir, "jx-foo"), filepath.Join(pluginBinDir, "jx-foo-2.0.0")}, shadowed)
+}
+
+func TestSearchPathDefaultsToPath(t *testing.T) {
+	pathDir := t.TempDir()
+	pluginBinDir := t.TempDir()
+	t.Setenv(plugins.PluginPathEnvVar, "")
+	t.Setenv("PATH", pathDir)
+
+	writeExecutable(t, filepath.Join(pathDir, "jx-foo"))
+	writeExecutable(t, filepath.Join(pluginBinDir, "jx-foo-1.0.0"))
+
+	s := plugins.NewSearchPath(pluginBinDir)
+	assert.Equal(t, []string{pathDir, pluginBinDir}, s.Dirs)
+	l := s.Find("jx-foo")
+	require.NotNil(t, l)
+	assert.Equal(t, filepath.Join(pathDir, "jx-foo"), l.Path, "should prefer local builds on the PATH")
+
+	// lets only stat the exact binaries in the PATH dirs rather than scanning them for versions
+	writeExecutable(t, filepath.Join(pathDir, "jx-bar-1.0.0"))
+	assert.Nil(t, s.Find("jx-bar"), "should not scan the PATH for versions")
+	assert.NotNil(t, s.FindVersion("jx-bar", "1.0.0"), "should find pinned versions on the PATH")
+	assert.Len(t, s.Locations("jx-bar"), 1, "should report every version in the search path")
+}
+
+func TestSearchPathConflictsSkipsUnreadableDirs(t *testing.T) {
+	t.Parallel()
+
+	pluginBinDir := t.TempDir()
+	notDir := filepath.Join(t.TempDir(), "file")
+	writeExecutable(t, notDir)
+	writeExecutable(t, filepath.Join(pluginBinDir, "jx-foo"))
+	writeExecutable(t, filepath.Join(pluginBinDir, "jx-foo-1.0.0"))
+
+	s := &plugins.SearchPath{Dirs: []string{notDir, pluginBinDir}, PluginBinDir: pluginBinDir}
+	conflicts, err := s.Conflicts()
+	require.NoError(t, err, "should ignore directories which cannot be read")
+	require.Len(t, conflicts, 1)
+	assert.Equal(t, "jx-foo", conflicts[0].Binary)
+}
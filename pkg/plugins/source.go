@@ -0,0 +1,567 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/httphelpers"
+	"github.com/jenkins-x/jx/pkg/config"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// SourcesFileName the name of the file in the jx home dir recording the repositories plugins were installed from
+	SourcesFileName = "plugin-sources.yaml"
+
+	// AssetManifestName the name of the optional release asset containing a jenkins.io/v1 Plugin resource which
+	// lists the binary of each platform. If a release has no manifest the binaries are found by naming convention
+	AssetManifestName = "plugin.yaml"
+
+	// GitLabTokenEnvVar the environment variable containing the token used to authenticate GitLab API requests
+	GitLabTokenEnvVar = "GITLAB_TOKEN"
+
+	// GitLabHostEnvVar the environment variable containing the GitLab host the $GITLAB_TOKEN is sent to.
+	// Defaults to gitlab.com
+	GitLabHostEnvVar = "GITLAB_HOST"
+
+	defaultGitLabHost = "gitlab.com"
+)
+
+var (
+	// sourcePlatforms the platforms whose binaries are looked up by naming convention
+	sourcePlatforms = []struct {
+		goos, goarch string
+	}{
+		{"linux", "amd64"}, {"linux", "arm64"}, {"linux", "386"}, {"linux", "arm"},
+		{"darwin", "amd64"}, {"darwin", "arm64"},
+		{"windows", "amd64"}, {"windows", "arm64"}, {"windows", "386"},
+	}
+
+	// platformAliases the names used for each OS and architecture in release asset names
+	platformAliases = map[string]string{
+		"linux":   "linux",
+		"darwin":  "darwin|macos|osx",
+		"windows": "windows|win",
+		"amd64":   "amd64|x86_64|x64",
+		"arm64":   "arm64|aarch64",
+		"386":     "386|i386|i686",
+		"arm":     "arm|armv6|armv7",
+	}
+
+	// assetExtensions the extensions of the release assets which can contain a plugin binary, most preferred first
+	assetExtensions = []string{".tar.gz", ".tgz", ".zip", ".tar.xz", ".gz", ".xz", ".exe"}
+
+	// otherAssetPattern matches the names of assets with other extensions such as checksums and signatures
+	otherAssetPattern = regexp.MustCompile(`\.[a-z]+[0-9]*$`)
+)
+
+// PluginSource a GitHub or GitLab repository whose releases contain the binaries of a plugin such as
+// github.com/myorg/jx-mytool
+type PluginSource struct {
+	// Host the git provider host such as github.com or gitlab.com
+	Host string
+
+	// Owner the owner of the repository which may contain sub groups on GitLab
+	Owner string
+
+	// Repo the name of the repository
+	Repo string
+}
+
+// SourcedPlugin records the repository a plugin was installed from
+type SourcedPlugin struct {
+	// Name the name of the plugin binary such as jx-mytool
+	Name string `json:"name"`
+
+	// Source the repository the plugin was installed from such as github.com/myorg/jx-mytool
+	Source string `json:"source"`
+
+	// InsecureSkipVerify disables the verification of the signatures of the plugin when it is upgraded or reinstalled
+	// as its releases are not signed by a trusted key
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
+}
+
+// Sources records the repositories plugins were installed from so that they can be upgraded from the same place
+type Sources struct {
+	// Plugins the plugins installed from repositories
+	Plugins []SourcedPlugin `json:"plugins,omitempty"`
+
+	// Path the file the sources are loaded from and saved to
+	Path string `json:"-"`
+}
+
+// releaseAsset a file attached to a release
+type releaseAsset struct {
+	Name string
+	URL  string
+}
+
+// IsPluginSource returns true if the name looks like a repository such as github.com/myorg/jx-mytool rather than
+// the name of a plugin
+func IsPluginSource(name string) bool {
+	name, _, _ = strings.Cut(name, "@")
+	return strings.Count(strings.TrimPrefix(name, "https://"), "/") >= 2 //nolint:mnd
+}
+
+// ParsePluginSource parses a repository such as github.com/myorg/jx-mytool with an optional @version suffix
+func ParsePluginSource(text string) (*PluginSource, string, error) {
+	name, version, _ := strings.Cut(text, "@")
+	name = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(name, "https://"), "/"), ".git")
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 || !strings.Contains(parts[0], ".") { //nolint:mnd
+		return nil, "", fmt.Errorf("invalid plugin repository %s, expected <host>/<owner>/<repo>[@version] such as github.com/myorg/jx-mytool", text)
+	}
+	for _, p := range parts {
+		if p == "" || p == "." || p == ".." {
+			return nil, "", fmt.Errorf("invalid plugin repository %s", text)
+		}
+	}
+	s := &PluginSource{
+		Host:  parts[0],
+		Owner: strings.Join(parts[1:len(parts)-1], "/"),
+		Repo:  parts[len(parts)-1],
+	}
+	return s, strings.TrimPrefix(version, "v"), nil
+}
+
+// String returns the repository such as github.com/myorg/jx-mytool
+func (s *PluginSource) String() string {
+	return s.Host + "/" + s.Owner + "/" + s.Repo
+}
+
+// BinaryName returns the name of the plugin binary such as jx-mytool
+func (s *PluginSource) BinaryName() string {
+	return BinaryName(s.Repo)
+}
+
+// IsGitLab returns true if the repository is hosted on GitLab
+func (s *PluginSource) IsGitLab() bool {
+	return strings.Contains(s.Host, "gitlab")
+}
+
+// SourcesFile returns the location of the file recording the repositories plugins were installed from
+func SourcesFile() (string, error) {
+	dir, err := config.HomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, SourcesFileName), nil
+}
+
+// LoadSources loads the repositories plugins were installed from in the jx home dir
+func LoadSources() (*Sources, error) {
+	path, err := SourcesFile()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the plugin sources file: %w", err)
+	}
+	return LoadSourcesFile(path)
+}
+
+// LoadSourcesFile loads the sources from the given file returning empty sources if the file does not exist
+func LoadSourcesFile(path string) (*Sources, error) {
+	s := &Sources{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read plugin sources %s: %w", path, err)
+	}
+	err = yaml.Unmarshal(data, s)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse plugin sources %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Save saves the sources to their file
+func (s *Sources) Save() error {
+	if s.Path == "" {
+		return fmt.Errorf("no file to save the plugin sources to")
+	}
+	sort.Slice(s.Plugins, func(i, j int) bool {
+		return s.Plugins[i].Name < s.Plugins[j].Name
+	})
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to marshal plugin sources: %w", err)
+	}
+	err = os.MkdirAll(filepath.Dir(s.Path), files.DefaultDirWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to create dir for plugin sources %s: %w", s.Path, err)
+	}
+	err = os.WriteFile(s.Path, data, files.DefaultFileWritePermissions)
+	if err != nil {
+		return fmt.Errorf("failed to save plugin sources %s: %w", s.Path, err)
+	}
+	return nil
+}
+
+// Find returns the repository the plugin binary was installed from or nil if it was not installed from one
+func (s *Sources) Find(binaryName string) *PluginSource {
+	if s == nil {
+		return nil
+	}
+	for _, p := range s.Plugins {
+		if p.Name == binaryName {
+			src, _, err := ParsePluginSource(p.Source)
+			if err != nil {
+				return nil
+			}
+			return src
+		}
+	}
+	return nil
+}
+
+// Set records the repository the plugin binary was installed from and whether its signatures are verified
+func (s *Sources) Set(binaryName string, src *PluginSource, insecureSkipVerify bool) {
+	for i := range s.Plugins {
+		if s.Plugins[i].Name == binaryName {
+			s.Plugins[i].Source = src.String()
+			s.Plugins[i].InsecureSkipVerify = insecureSkipVerify
+			return
+		}
+	}
+	s.Plugins = append(s.Plugins, SourcedPlugin{Name: binaryName, Source: src.String(), InsecureSkipVerify: insecureSkipVerify})
+}
+
+// SkipVerify returns true if the signatures of the plugin binary installed from a repository are not verified
+func (s *Sources) SkipVerify(binaryName string) bool {
+	if s == nil {
+		return false
+	}
+	for _, p := range s.Plugins {
+		if p.Name == binaryName {
+			return p.InsecureSkipVerify
+		}
+	}
+	return false
+}
+
+// Remove removes the record of the repository the plugin binary was installed from returning true if there was one
+func (s *Sources) Remove(binaryName string) bool {
+	for i := range s.Plugins {
+		if s.Plugins[i].Name == binaryName {
+			s.Plugins = append(s.Plugins[:i], s.Plugins[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the binary names of the plugins installed from repositories
+func (s *Sources) Names() []string {
+	if s == nil {
+		return nil
+	}
+	var answer []string
+	for _, p := range s.Plugins {
+		answer = append(answer, p.Name)
+	}
+	return answer
+}
+
+// InstallFromSource installs the given version of the plugin from the releases of the repository, or the latest
+// release if the version is empty, and returns the path to the binary
+func (i *Installer) InstallFromSource(pluginBinDir string, src *PluginSource, version string) (string, error) {
+	plugin, err := i.ResolveSource(src, version)
+	if err != nil {
+		return "", err
+	}
+	return i.EnsurePluginInstalled(*plugin, pluginBinDir)
+}
+
+// ResolveSource returns the plugin of the given version of the repository, or its latest release if the
+// version is empty, using the asset manifest of the release if it has one or else the names of its assets.
+//
+// The asset manifest must be for the binary name of the repository
+func (i *Installer) ResolveSource(src *PluginSource, version string) (*jenkinsv1.Plugin, error) {
+	tagName, assets, err := i.sourceRelease(src, version)
+	if err != nil {
+		return nil, err
+	}
+	version = strings.TrimPrefix(tagName, "v")
+	err = ValidateVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag %s of %s: %w", tagName, src.String(), err)
+	}
+
+	binaryName := src.BinaryName()
+	plugin := &jenkinsv1.Plugin{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: jenkinsv1.SchemeGroupVersion.String(),
+			Kind:       "Plugin",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: strings.TrimPrefix(binaryName, "jx-"),
+		},
+		Spec: jenkinsv1.PluginSpec{
+			SubCommand:  strings.TrimPrefix(binaryName, "jx-"),
+			Description: "plugin installed from " + src.String(),
+			Name:        binaryName,
+			Version:     version,
+		},
+	}
+
+	for _, a := range assets {
+		if a.Name != AssetManifestName {
+			continue
+		}
+		data, err := Download(i.Client, a.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download the asset manifest of %s release %s: %w", src.String(), tagName, err)
+		}
+		list, err := ParseIndex(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the asset manifest of %s release %s: %w", src.String(), tagName, err)
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("the asset manifest of %s release %s contains no valid plugin", src.String(), tagName)
+		}
+		manifest := &list[0]
+		if manifest.Spec.Name != binaryName {
+			return nil, fmt.Errorf("the asset manifest of %s release %s is for plugin %s rather than %s", src.String(), tagName, manifest.Spec.Name, binaryName)
+		}
+		manifest.Spec.Version = version
+		return manifest, nil
+	}
+
+	plugin.Spec.Binaries = platformBinaries(binaryName, assets)
+	if len(plugin.Spec.Binaries) == 0 {
+		return nil, fmt.Errorf("%s release %s has no %s asset or assets named with the OS and architecture such as %s-%s-%s.tar.gz", src.String(), tagName, AssetManifestName, binaryName, "linux", "amd64")
+	}
+	return plugin, nil
+}
+
+// LatestSourceVersion returns the version of the latest release of the repository
+func (i *Installer) LatestSourceVersion(src *PluginSource) (string, error) {
+	tagName, _, err := i.sourceRelease(src, "")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(tagName, "v"), nil
+}
+
+// sourceRelease returns the tag name and assets of the given version of the repository or its latest release
+func (i *Installer) sourceRelease(src *PluginSource, version string) (string, []releaseAsset, error) {
+	var tags []string
+	if version != "" {
+		// lets support repositories which tag releases with or without the v prefix
+		tags = []string{"v" + version, version}
+	} else {
+		tags = []string{""}
+	}
+
+	var lastErr error
+	for _, tag := range tags {
+		tagName, assets, found, err := i.fetchSourceRelease(src, tag)
+		if err != nil {
+			return "", nil, err
+		}
+		if found {
+			return tagName, assets, nil
+		}
+		lastErr = fmt.Errorf("failed to find release %s of %s", tag, src.String())
+	}
+	if version == "" {
+		lastErr = fmt.Errorf("%s has no releases", src.String())
+	}
+	return "", nil, lastErr
+}
+
+// fetchSourceRelease fetches the release with the given tag or the latest release if the tag is empty.
+// Returns false if the release does not exist
+func (i *Installer) fetchSourceRelease(src *PluginSource, tag string) (string, []releaseAsset, bool, error) {
+	if IsOffline() {
+		return "", nil, false, &OfflineError{Binary: src.BinaryName(), Version: strings.TrimPrefix(tag, "v")}
+	}
+	var u string
+	switch {
+	case src.IsGitLab():
+		u = "https://" + src.Host + "/api/v4/projects/" + url.PathEscape(src.Owner+"/"+src.Repo) + "/releases/"
+		if tag == "" {
+			u += "permalink/latest"
+		} else {
+			u += url.PathEscape(tag)
+		}
+	default:
+		path := "repos/" + src.Owner + "/" + src.Repo + "/releases/"
+		if tag == "" {
+			path += "latest"
+		} else {
+			path += "tags/" + url.PathEscape(tag)
+		}
+		if src.Host == "github.com" {
+			u = i.Mirror.APIURL(path)
+		} else {
+			// GitHub Enterprise
+			u = "https://" + src.Host + "/api/v3/" + path
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, http.NoBody)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to create http request for %s: %w", u, err)
+	}
+	if src.IsGitLab() {
+		// lets only send the token to the configured GitLab host
+		token := ""
+		if src.Host == gitLabHost() {
+			token = os.Getenv(GitLabTokenEnvVar)
+		}
+		if token == "" {
+			token = gitCredentialPassword(src.Host)
+		}
+		if token != "" {
+			req.Header.Set("PRIVATE-TOKEN", token)
+		}
+	} else {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		token := ""
+		if src.Host == "github.com" {
+			token = i.githubToken(req.URL)
+		} else {
+			token = gitCredentialPassword(src.Host)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	client := i.Client
+	if client == nil {
+		client = httphelpers.GetClient()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to GET endpoint %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", nil, false, nil
+	case !src.IsGitLab() && isRateLimited(resp):
+		return "", nil, false, newRateLimitError(u, resp)
+	case resp.StatusCode != http.StatusOK:
+		return "", nil, false, fmt.Errorf("failed to GET endpoint %s with status %s", u, resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to read response from %s: %w", u, err)
+	}
+	tagName, assets, err := parseSourceRelease(body, src.IsGitLab())
+	if err != nil {
+		return "", nil, false, fmt.Errorf("failed to unmarshal release from %s: %w", u, err)
+	}
+	return tagName, assets, true, nil
+}
+
+// gitLabHost returns the GitLab host the $GITLAB_TOKEN is sent to
+func gitLabHost() string {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(os.Getenv(GitLabHostEnvVar), "https://"), "http://"), "/")
+	if host == "" {
+		return defaultGitLabHost
+	}
+	return host
+}
+
+// parseSourceRelease parses the tag name and assets of a GitHub or GitLab release
+func parseSourceRelease(data []byte, gitlab bool) (string, []releaseAsset, error) {
+	var assets []releaseAsset
+	if gitlab {
+		release := &struct {
+			TagName string `json:"tag_name"`
+			Assets  struct {
+				Links []struct {
+					Name           string `json:"name"`
+					URL            string `json:"url"`
+					DirectAssetURL string `json:"direct_asset_url"`
+				} `json:"links"`
+			} `json:"assets"`
+		}{}
+		err := json.Unmarshal(data, release)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, l := range release.Assets.Links {
+			u := l.DirectAssetURL
+			if u == "" {
+				u = l.URL
+			}
+			assets = append(assets, releaseAsset{Name: l.Name, URL: u})
+		}
+		return release.TagName, assets, nil
+	}
+
+	release := &struct {
+		TagName string `json:"tag_name"`
+		Assets  []struct {
+			Name               string `json:"name"`
+			BrowserDownloadURL string `json:"browser_download_url"`
+		} `json:"assets"`
+	}{}
+	err := json.Unmarshal(data, release)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, a := range release.Assets {
+		assets = append(assets, releaseAsset{Name: a.Name, URL: a.BrowserDownloadURL})
+	}
+	return release.TagName, assets, nil
+}
+
+// platformBinaries returns the binary of each platform found by the names of the release assets which must contain
+// the OS and architecture such as jx-mytool-linux-amd64.tar.gz or jx-mytool_Darwin_x86_64.zip
+func platformBinaries(binaryName string, assets []releaseAsset) []jenkinsv1.Binary {
+	var answer []jenkinsv1.Binary
+	for _, p := range sourcePlatforms {
+		osPattern := regexp.MustCompile(`(^|[-_.])(` + platformAliases[p.goos] + `)([-_.]|$)`)
+		archPattern := regexp.MustCompile(`(^|[-_.])(` + platformAliases[p.goarch] + `)([-_.]|$)`)
+		best, bestRank := "", -1
+		for _, a := range assets {
+			name := strings.ToLower(a.Name)
+			if !strings.HasPrefix(name, strings.ToLower(strings.TrimPrefix(binaryName, "jx-"))) && !strings.HasPrefix(name, strings.ToLower(binaryName)) {
+				continue
+			}
+			if !osPattern.MatchString(name) || !archPattern.MatchString(name) {
+				continue
+			}
+			if rank := assetRank(name); rank >= 0 && (bestRank < 0 || rank < bestRank) {
+				best, bestRank = a.URL, rank
+			}
+		}
+		if best != "" {
+			answer = append(answer, jenkinsv1.Binary{Goos: p.goos, Goarch: p.goarch, URL: best})
+		}
+	}
+	return answer
+}
+
+// assetRank returns the preference of the asset by its extension, lowest first, or -1 if it cannot contain a
+// plugin binary such as a checksum or signature
+func assetRank(name string) int {
+	for i, ext := range assetExtensions {
+		if strings.HasSuffix(name, ext) {
+			return i
+		}
+	}
+	if otherAssetPattern.MatchString(name) {
+		return -1
+	}
+	// lets assume assets without an extension are the binary itself
+	return len(assetExtensions)
+}
@@ -0,0 +1,247 @@
+package plugins_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/config"
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// rewriteTransport sends every request to the test server
+type rewriteTransport struct {
+	server *httptest.Server
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, err := url.Parse(t.server.URL)
+	if err != nil {
+		return nil, err
+	}
+	req = req.Clone(req.Context())
+	req.URL.Scheme = u.Scheme
+	req.URL.Host = u.Host
+	return t.server.Client().Transport.RoundTrip(req)
+}
+
+func newSourceInstaller(t *testing.T, server *httptest.Server) *plugins.Installer {
+	return &plugins.Installer{
+		Lock:       &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")},
+		SkipVerify: true,
+		Mirror:     &config.Mirror{GitHubAPIURL: server.URL + "/api/"},
+		Client:     &http.Client{Transport: &rewriteTransport{server: server}},
+		Token:      "test-token",
+	}
+}
+
+func TestParsePluginSource(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		text    string
+		source  string
+		repo    string
+		binary  string
+		version string
+		gitlab  bool
+	}{
+		{text: "github.com/myorg/jx-mytool", source: "github.com/myorg/jx-mytool", repo: "jx-mytool", binary: "jx-mytool"},
+		{text: "https://github.com/myorg/mytool.git@v1.2.3", source: "github.com/myorg/mytool", repo: "mytool", binary: "jx-mytool", version: "1.2.3"},
+		{text: "gitlab.com/mygroup/sub/jx-mytool@1.0.0", source: "gitlab.com/mygroup/sub/jx-mytool", repo: "jx-mytool", binary: "jx-mytool", version: "1.0.0", gitlab: true},
+	}
+	for _, tc := range testCases {
+		assert.True(t, plugins.IsPluginSource(tc.text), "for %s", tc.text)
+		src, version, err := plugins.ParsePluginSource(tc.text)
+		require.NoError(t, err, "for %s", tc.text)
+		assert.Equal(t, tc.source, src.String(), "for %s", tc.text)
+		assert.Equal(t, tc.repo, src.Repo, "for %s", tc.text)
+		assert.Equal(t, tc.binary, src.BinaryName(), "for %s", tc.text)
+		assert.Equal(t, tc.version, version, "for %s", tc.text)
+		assert.Equal(t, tc.gitlab, src.IsGitLab(), "for %s", tc.text)
+	}
+
+	for _, text := range []string{"gitops", "jx-gitops", "myorg/jx-mytool", "github.com/myorg/../jx-mytool", "github.com//jx-mytool"} {
+		_, _, err := plugins.ParsePluginSource(text)
+		assert.Error(t, err, "for %s", text)
+	}
+	assert.False(t, plugins.IsPluginSource("gitops"))
+}
+
+func TestResolveSourceByNamingConvention(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/repos/myorg/jx-mytool/releases/tags/v1.2.3":
+			http.NotFound(w, r)
+		case "/api/repos/myorg/jx-mytool/releases/tags/1.2.3":
+			fmt.Fprint(w, `{"tag_name": "1.2.3", "assets": [
+				{"name": "checksums.txt", "browser_download_url": "https://example.com/checksums.txt"},
+				{"name": "jx-mytool-linux-amd64.tar.gz.sig", "browser_download_url": "https://example.com/linux.sig"},
+				{"name": "jx-mytool-linux-amd64", "browser_download_url": "https://example.com/linux-raw"},
+				{"name": "jx-mytool-linux-amd64.tar.gz", "browser_download_url": "https://example.com/linux.tar.gz"},
+				{"name": "jx-mytool_Darwin_x86_64.zip", "browser_download_url": "https://example.com/darwin.zip"},
+				{"name": "jx-mytool-darwin-arm64", "browser_download_url": "https://example.com/darwin-arm64"},
+				{"name": "jx-mytool-windows-amd64.exe", "browser_download_url": "https://example.com/windows.exe"}
+			]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	installer := newSourceInstaller(t, server)
+	src, version, err := plugins.ParsePluginSource("github.com/myorg/jx-mytool@1.2.3")
+	require.NoError(t, err)
+	plugin, err := installer.ResolveSource(src, version)
+	require.NoError(t, err)
+
+	assert.Equal(t, "jx-mytool", plugin.Spec.Name)
+	assert.Equal(t, "1.2.3", plugin.Spec.Version)
+	urls := map[string]string{}
+	for _, b := range plugin.Spec.Binaries {
+		urls[b.Goos+"/"+b.Goarch] = b.URL
+	}
+	assert.Equal(t, map[string]string{
+		"linux/amd64":   "https://example.com/linux.tar.gz",
+		"darwin/amd64":  "https://example.com/darwin.zip",
+		"darwin/arm64":  "https://example.com/darwin-arm64",
+		"windows/amd64": "https://example.com/windows.exe",
+	}, urls)
+
+	_, err = installer.ResolveSource(src, "2.0.0")
+	assert.Error(t, err, "should fail to find a missing release")
+}
+
+func TestInstallFromGitLabSourceWithManifest(t *testing.T) {
+	t.Parallel()
+
+	archive := createArchive(t, "jx-mytool", []byte("#!/bin/sh\necho mytool\n"))
+	manifestName := "mytool"
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/mygroup%2Fjx-mytool/releases/permalink/latest":
+			fmt.Fprintf(w, `{"tag_name": "v2.0.0", "assets": {"links": [
+				{"name": "plugin.yaml", "url": "https://example.com/ignored", "direct_asset_url": "%s/manifest"}
+			]}}`, server.URL)
+		case "/manifest":
+			fmt.Fprintf(w, `apiVersion: jenkins.io/v1
+kind: Plugin
+metadata:
+  name: %s
+spec:
+  name: jx-%s
+  version: 0.0.1
+  binaries:
+  - goos: %s
+    goarch: %s
+    url: %s/archive.tar.gz
+`, manifestName, manifestName, runtime.GOOS, runtime.GOARCH, server.URL)
+		case "/archive.tar.gz":
+			w.Write(archive) //nolint:errcheck
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	installer := newSourceInstaller(t, server)
+	src, _, err := plugins.ParsePluginSource("gitlab.com/mygroup/jx-mytool")
+	require.NoError(t, err)
+
+	pluginBinDir := t.TempDir()
+	path, err := installer.InstallFromSource(pluginBinDir, src, "")
+	require.NoError(t, err)
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-mytool", "2.0.0"), path, "should use the manifest with the release version")
+	assert.FileExists(t, path)
+
+	// plugins installed from a repository are upgraded from the repository
+	installer.Sources = &plugins.Sources{}
+	installer.Sources.Set("jx-mytool", src, false)
+	path, err = installer.InstallStandardPlugin(pluginBinDir, "mytool")
+	require.NoError(t, err)
+	assert.Equal(t, plugins.PluginBinary(pluginBinDir, "jx-mytool", "2.0.0"), path)
+
+	drifts, err := installer.Drift(pluginBinDir, nil, true)
+	require.NoError(t, err)
+	require.Len(t, drifts, 1)
+	assert.Equal(t, plugins.DriftActionNone, drifts[0].Action, "error: %s", drifts[0].Error)
+	assert.Equal(t, "2.0.0", drifts[0].Target)
+
+	// the manifest must be for the plugin of the repository
+	manifestName = "other"
+	_, err = installer.ResolveSource(src, "")
+	require.Error(t, err, "should reject a manifest for another plugin")
+	assert.Contains(t, err.Error(), "rather than jx-mytool")
+}
+
+func TestSourcesFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), plugins.SourcesFileName)
+	sources, err := plugins.LoadSourcesFile(path)
+	require.NoError(t, err)
+	assert.Nil(t, sources.Find("jx-mytool"))
+
+	src, _, err := plugins.ParsePluginSource("github.com/myorg/jx-mytool")
+	require.NoError(t, err)
+	sources.Set("jx-mytool", src, true)
+	require.NoError(t, sources.Save())
+
+	sources, err = plugins.LoadSourcesFile(path)
+	require.NoError(t, err)
+	require.NotNil(t, sources.Find("jx-mytool"))
+	assert.Equal(t, "github.com/myorg/jx-mytool", sources.Find("jx-mytool").String())
+	assert.True(t, sources.SkipVerify("jx-mytool"), "should remember to skip verification on upgrade")
+	assert.False(t, sources.SkipVerify("jx-other"))
+
+	sources.Set("jx-mytool", src, false)
+	assert.False(t, sources.SkipVerify("jx-mytool"), "should verify once reinstalled without --insecure-skip-verify")
+	assert.Equal(t, []string{"jx-mytool"}, sources.Names())
+
+	assert.True(t, sources.Remove("jx-mytool"))
+	assert.False(t, sources.Remove("jx-mytool"))
+	assert.Empty(t, sources.Names())
+}
+
+func TestGitLabTokenOnlySentToConfiguredHost(t *testing.T) {
+	t.Setenv(plugins.GitLabTokenEnvVar, "gitlab-token")
+	t.Setenv(plugins.GitLabHostEnvVar, "https://gitlab.acme.com/")
+
+	tokens := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens[r.Host] = r.Header.Get("PRIVATE-TOKEN")
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	installer := newSourceInstaller(t, server)
+	installer.Client.Transport = &hostRecordingTransport{rewriteTransport{server: server}}
+	for _, repo := range []string{"gitlab.acme.com/mygroup/jx-mytool", "gitlab.evil.com/mygroup/jx-mytool"} {
+		src, _, err := plugins.ParsePluginSource(repo)
+		require.NoError(t, err)
+		_, err = installer.InstallFromSource(t.TempDir(), src, "")
+		require.Error(t, err, "should fail to find a missing release")
+	}
+	assert.Equal(t, "gitlab-token", tokens["gitlab.acme.com"], "should send the token to the configured host")
+	assert.Empty(t, tokens["gitlab.evil.com"], "should not send the token to other hosts")
+}
+
+// hostRecordingTransport sends every request to the test server keeping the original host header
+type hostRecordingTransport struct {
+	rewriteTransport
+}
+
+func (t *hostRecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Host = req.URL.Host
+	return t.rewriteTransport.RoundTrip(req)
+}
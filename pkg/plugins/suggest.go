@@ -0,0 +1,197 @@
+package plugins
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// suggestionMaxDistance the maximum edit distance of each word of a suggested command
+	suggestionMaxDistance = 2
+
+	// maxSuggestions the maximum number of commands suggested for an unknown command
+	maxSuggestions = 5
+)
+
+// suggestion a command which is similar to an unknown command
+type suggestion struct {
+	path  []string
+	score int
+}
+
+// RegisterCachedPluginCommands adds the commands of the managed and installed plugins to the command tree using
+// only the cached plugin metadata so that no plugins are invoked such as during shell completion
+func RegisterCachedPluginCommands(rootCmd *cobra.Command) []*cobra.Command {
+	loader := NewMetadataLoader()
+	loader.CacheOnly = true
+	return registerPluginCommands(rootCmd, true, loader)
+}
+
+// RegisterIndexPluginCommands adds the plugins of the cached copies of the configured plugin indexes which are not
+// already in the command tree without using the network so that plugins which are not installed are also suggested
+func RegisterIndexPluginCommands(rootCmd *cobra.Command) {
+	loader, err := NewIndexLoader()
+	if err != nil {
+		log.Logger().Debugf("failed to load plugin indexes: %s", err.Error())
+		return
+	}
+	loader.CacheOnly = true
+	for i := range loader.Indexes {
+		idx := &loader.Indexes[i]
+		list, err := loader.LoadIndex(idx)
+		if err != nil {
+			log.Logger().Debugf("ignoring plugin index %s: %s", idx.Name, err.Error())
+			continue
+		}
+		for j := range list {
+			addIndexPluginCommand(rootCmd, idx.Name, &list[j].Spec)
+		}
+	}
+}
+
+// addIndexPluginCommand adds the commands of the plugin binary such as jx-foo-bar which are not in the command tree
+func addIndexPluginCommand(rootCmd *cobra.Command, index string, spec *jenkinsv1.PluginSpec) {
+	name := strings.TrimPrefix(spec.Name, "jx-")
+	if name == spec.Name || name == "" {
+		return
+	}
+	var args []string
+	for _, arg := range strings.Split(name, "-") {
+		args = append(args, strings.ReplaceAll(arg, "_", "-"))
+	}
+	parentCmd, remainingArgs, _ := rootCmd.Find(args)
+	if parentCmd == nil {
+		parentCmd = rootCmd
+	}
+	for _, arg := range remainingArgs {
+		cmd := &cobra.Command{
+			Use:                arg,
+			Short:              fmt.Sprintf("The plugin %s in plugin index %s", spec.Name, index),
+			DisableFlagParsing: true,
+			// A Run is required for it to be a valid command
+			Run: func(_ *cobra.Command, _ []string) {},
+		}
+		parentCmd.AddCommand(cmd)
+		parentCmd = cmd
+	}
+}
+
+// SuggestCommands returns the commands in the command tree which are most similar to the arguments of an unknown
+// command such as "jx gitops helmfile", ranked by similarity.
+//
+// Each word of a command matches if it starts with the typed word or is within a small edit distance of it so that
+// the built in commands, aliases, plugins and plugin subcommands in the tree are all suggested. Commands are suggested
+// once by their name even if their aliases are similar too
+func SuggestCommands(rootCmd *cobra.Command, args []string) []string {
+	var words []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		words = append(words, strings.ToLower(arg))
+	}
+	if len(words) == 0 {
+		return nil
+	}
+
+	best := map[*cobra.Command]*suggestion{}
+	var walk func(cmd *cobra.Command, path []string, score int, exact bool)
+	walk = func(cmd *cobra.Command, path []string, score int, exact bool) {
+		depth := len(path)
+		if depth >= len(words) {
+			return
+		}
+		for _, child := range cmd.Commands() {
+			if !child.IsAvailableCommand() {
+				continue
+			}
+			// lets use the most similar of the name and aliases of the command
+			d, ok := -1, false
+			for _, name := range append([]string{child.Name()}, child.Aliases...) {
+				nameDistance, nameOK := wordDistance(words[depth], strings.ToLower(name))
+				if nameOK && (!ok || nameDistance < d) {
+					d, ok = nameDistance, true
+				}
+			}
+			if !ok {
+				continue
+			}
+			childPath := append(append([]string{}, path...), child.Name())
+			childScore := score + d
+			childExact := exact && d == 0
+
+			// a command whose words all match exactly is the parent of the unknown command, not a suggestion
+			if !childExact {
+				if s := best[child]; s == nil || childScore < s.score {
+					best[child] = &suggestion{path: childPath, score: childScore}
+				}
+			}
+			walk(child, childPath, childScore, childExact)
+		}
+	}
+	walk(rootCmd, nil, 0, true)
+
+	suggestions := make([]*suggestion, 0, len(best))
+	for _, s := range best {
+		suggestions = append(suggestions, s)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		a, b := suggestions[i], suggestions[j]
+		if a.score != b.score {
+			return a.score < b.score
+		}
+		// lets prefer the most specific command
+		if len(a.path) != len(b.path) {
+			return len(a.path) > len(b.path)
+		}
+		return strings.Join(a.path, " ") < strings.Join(b.path, " ")
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	answer := make([]string, 0, len(suggestions))
+	for _, s := range suggestions {
+		answer = append(answer, rootCmd.Name()+" "+strings.Join(s.path, " "))
+	}
+	return answer
+}
+
+// wordDistance returns the distance between the typed word and the name of a command and whether they are similar
+func wordDistance(word, name string) (int, bool) {
+	if word == name {
+		return 0, true
+	}
+	if len(word) > 1 && strings.HasPrefix(name, word) {
+		return 1, true
+	}
+	d := editDistance(word, name)
+	return d, d <= suggestionMaxDistance
+}
+
+// editDistance returns the Levenshtein distance between the two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,48 @@
+package plugins_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSuggestTestCommand() *cobra.Command {
+	run := func(_ *cobra.Command, _ []string) {}
+	rootCmd := &cobra.Command{Use: "jx", Run: run}
+	getCmd := &cobra.Command{Use: "get", Run: run}
+	getCmd.AddCommand(&cobra.Command{Use: "envs", Aliases: []string{"env"}, Run: run})
+	gitops := &cobra.Command{Use: "gitops", Run: run}
+	gitops.AddCommand(&cobra.Command{Use: "helmfile", Run: run}, &cobra.Command{Use: "helm", Run: run})
+	rootCmd.AddCommand(getCmd, gitops, &cobra.Command{Use: "secret", Run: run}, &cobra.Command{Use: "hidden", Hidden: true, Run: run})
+	return rootCmd
+}
+
+func TestSuggestCommands(t *testing.T) {
+	t.Parallel()
+
+	rootCmd := newSuggestTestCommand()
+	testCases := map[string][]string{
+		"gitop":              {"jx gitops"},
+		"gitop helmfile":     {"jx gitops helmfile", "jx gitops"},
+		"gitops helmfil":     {"jx gitops helmfile"},
+		"gitops hlem":        {"jx gitops helm"},
+		"sceret":             {"jx secret"},
+		"secrets --name foo": {"jx secret"},
+		"get enviro":         nil,
+		"get en":             {"jx get envs"},
+		"get evn":            {"jx get envs"},
+		"hiden":              nil,
+		"doesnotexist":       nil,
+	}
+	for args, expected := range testCases {
+		actual := plugins.SuggestCommands(rootCmd, strings.Fields(args))
+		if len(expected) == 0 {
+			assert.Empty(t, actual, "for %s", args)
+			continue
+		}
+		assert.Equal(t, expected, actual, "for %s", args)
+	}
+}
@@ -44,8 +44,8 @@ const (
 )
 
 var (
-	// Plugins default plugins
-	Plugins = []jenkinsv1.Plugin{
+	// DefaultPlugins the managed plugins using the versions compiled into this version of jx
+	DefaultPlugins = []jenkinsv1.Plugin{
 		extensions.CreateJXPlugin(jenkinsxPluginsOrganisation, "admin", AdminVersion),
 		extensions.CreateJXPlugin(jenkinsxPluginsOrganisation, "application", ApplicationVersion),
 		extensions.CreateJXPlugin(jenkinsxPluginsOrganisation, "changelog", ChangelogVersion),
@@ -60,11 +60,21 @@ var (
 		extensions.CreateJXPlugin(jenkinsxPluginsOrganisation, "verify", VerifyVersion),
 	}
 
+	// Plugins the managed plugins which default to DefaultPlugins unless resolved from a version stream
+	Plugins []jenkinsv1.Plugin
+
 	// PluginMap a map of plugin names like `jx-gitops` to the Plugin object
-	PluginMap = map[string]*jenkinsv1.Plugin{}
+	PluginMap map[string]*jenkinsv1.Plugin
 )
 
 func init() {
+	SetPlugins(DefaultPlugins)
+}
+
+// SetPlugins sets the managed plugins and rebuilds the PluginMap
+func SetPlugins(list []jenkinsv1.Plugin) {
+	Plugins = append([]jenkinsv1.Plugin{}, list...)
+	PluginMap = map[string]*jenkinsv1.Plugin{}
 	for i := range Plugins {
 		plugin := &Plugins[i]
 		PluginMap[plugin.Spec.Name] = plugin
@@ -0,0 +1,159 @@
+package plugins
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	jenkinsv1 "github.com/jenkins-x/jx-api/v4/pkg/apis/jenkins.io/v1"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/extensions"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/files"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/termcolor"
+	"github.com/jenkins-x/jx-helpers/v3/pkg/versionstream"
+	"github.com/jenkins-x/jx-logging/v3/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// VersionStreamDirEnvVar the environment variable containing the version stream dir used to resolve the
+	// versions of the managed plugins
+	VersionStreamDirEnvVar = "JX_VERSION_STREAM_DIR"
+
+	// versionStreamDirName the name of the version stream dir in a cluster git repository
+	versionStreamDirName = "versionStream"
+
+	// kptfileName the name of the file kpt uses to record the upstream git repository of the version stream
+	kptfileName = "Kptfile"
+)
+
+// kptfile the part of a Kptfile containing the upstream git repository of a package
+type kptfile struct {
+	Upstream struct {
+		Git struct {
+			Repo string `json:"repo"`
+		} `json:"git"`
+	} `json:"upstream"`
+}
+
+// FindVersionStreamDir returns the version stream dir to resolve plugin versions from.
+//
+// The given dir is used if specified, then the $JX_VERSION_STREAM_DIR environment variable and finally
+// the versionStream dir in the current directory if its Kptfile has an upstream git repository like
+// 'jx upgrade cli' uses when inside a cluster git repository.
+// An empty string is returned if there is no version stream
+func FindVersionStreamDir(dir string) (string, error) {
+	if dir == "" {
+		dir = os.Getenv(VersionStreamDirEnvVar)
+	}
+	if dir != "" {
+		exists, err := files.DirExists(dir)
+		if err != nil {
+			return "", fmt.Errorf("failed to check if dir exists %s: %w", dir, err)
+		}
+		if !exists {
+			return "", fmt.Errorf("version stream dir %s does not exist", dir)
+		}
+		return dir, nil
+	}
+	upstream, err := VersionStreamUpstream(versionStreamDirName)
+	if err != nil {
+		return "", err
+	}
+	if upstream == "" {
+		return "", nil
+	}
+	log.Logger().Debugf("using version stream dir %s from upstream %s", versionStreamDirName, upstream)
+	return versionStreamDirName, nil
+}
+
+// VersionStreamUpstream returns the upstream git repository in the Kptfile of the version stream dir or an empty
+// string if there is no Kptfile
+func VersionStreamUpstream(dir string) (string, error) {
+	path := filepath.Join(dir, kptfileName)
+	exists, err := files.FileExists(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to check if file exists %s: %w", path, err)
+	}
+	if !exists {
+		return "", nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	kf := &kptfile{}
+	err = yaml.Unmarshal(data, kf)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return strings.TrimSpace(kf.Upstream.Git.Repo), nil
+}
+
+// ResolvePlugins returns the default plugins using the versions in the version stream dir
+// falling back to the compiled in versions for any plugins not in the version stream.
+//
+// Versions which are not compiled in have no checksums in the default lock. If they have none in the given lock
+// either an error is returned for a strict lock, otherwise a warning is logged as they are trusted on first use
+func ResolvePlugins(versionStreamDir string, lock *Lock) ([]jenkinsv1.Plugin, error) {
+	resolver := &versionstream.VersionResolver{
+		VersionsDir: versionStreamDir,
+	}
+	answer := make([]jenkinsv1.Plugin, 0, len(DefaultPlugins))
+	for i := range DefaultPlugins {
+		p := DefaultPlugins[i]
+		v, err := resolver.StableVersionNumber(versionstream.KindPackage, p.Spec.Name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve the version of plugin %s from version stream %s: %w", p.Spec.Name, versionStreamDir, err)
+		}
+		v = strings.TrimPrefix(v, "v")
+		if v != "" && v != p.Spec.Version {
+			log.Logger().Debugf("using plugin %s version %s from version stream %s", p.Spec.Name, v, versionStreamDir)
+			if lock.Checksum(p.Spec.Name, v, Platform()) == "" {
+				if lock.Strict {
+					return nil, fmt.Errorf("plugin %s version %s from version stream %s has no checksum for %s in the strict plugin lock %s", p.Spec.Name, v, versionStreamDir, Platform(), lock.Path)
+				}
+				log.Logger().Warnf("plugin %s version %s from version stream %s has no checksum for %s in the plugin lock so its checksum will be trusted and recorded when it is first installed",
+					termcolor.ColorInfo(p.Spec.Name), termcolor.ColorInfo(v), versionStreamDir, Platform())
+			}
+			p = extensions.CreateJXPlugin(jenkinsxPluginsOrganisation, p.Name, v)
+		}
+		answer = append(answer, p)
+	}
+	return answer, nil
+}
+
+// UseVersionStream resolves the managed plugins from the given version stream dir or the detected version
+// stream if it is empty. The compiled in versions are used if there is no version stream.
+//
+// A version stream detected in the current directory may come from any git repository that has been cloned so
+// the plugin lock is treated as strict for it: every version it picks must already have a checksum in the lock.
+// Only a version stream given explicitly via the dir or $JX_VERSION_STREAM_DIR has its versions trusted on first use
+func UseVersionStream(dir string) error {
+	detected := dir == "" && os.Getenv(VersionStreamDirEnvVar) == ""
+	versionStreamDir, err := FindVersionStreamDir(dir)
+	if err != nil {
+		return err
+	}
+	if versionStreamDir == "" {
+		SetPlugins(DefaultPlugins)
+		return nil
+	}
+	lock, err := LoadLock()
+	if err != nil {
+		return fmt.Errorf("failed to load plugin lock: %w", err)
+	}
+	if detected {
+		lock.Strict = true
+	}
+	list, err := ResolvePlugins(versionStreamDir, lock)
+	if err != nil {
+		if detected {
+			return fmt.Errorf("%w. Specify the version stream dir explicitly such as via $%s to trust its versions", err, VersionStreamDirEnvVar)
+		}
+		return err
+	}
+	log.Logger().Debugf("resolved plugin versions from version stream %s", termcolor.ColorInfo(versionStreamDir))
+	SetPlugins(list)
+	return nil
+}
@@ -0,0 +1,125 @@
+package plugins_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/plugins"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createVersionStream creates a version stream dir containing the given package versions
+func createVersionStream(t *testing.T, versions map[string]string) string {
+	dir := t.TempDir()
+	packagesDir := filepath.Join(dir, "packages")
+	require.NoError(t, os.MkdirAll(packagesDir, 0o700))
+	for name, version := range versions {
+		err := os.WriteFile(filepath.Join(packagesDir, name+".yml"), []byte("version: "+version+"\n"), 0o600)
+		require.NoError(t, err)
+	}
+	return dir
+}
+
+func TestResolvePlugins(t *testing.T) {
+	t.Parallel()
+
+	dir := createVersionStream(t, map[string]string{"jx-gitops": "v9.9.9"})
+
+	lock := &plugins.Lock{Path: filepath.Join(t.TempDir(), "plugins.lock")}
+	list, err := plugins.ResolvePlugins(dir, lock)
+	require.NoError(t, err)
+	require.Len(t, list, len(plugins.DefaultPlugins))
+	for _, p := range list {
+		switch p.Spec.Name {
+		case "jx-gitops":
+			assert.Equal(t, "9.9.9", p.Spec.Version)
+			url, err := plugins.PluginURL(&p.Spec)
+			require.NoError(t, err)
+			assert.Contains(t, url, "/v9.9.9/")
+		case "jx-secret":
+			assert.Equal(t, plugins.SecretVersion, p.Spec.Version, "should fall back to the default version")
+		}
+	}
+
+	lock.Strict = true
+	_, err = plugins.ResolvePlugins(dir, lock)
+	require.Error(t, err, "should fail for stream versions without a checksum in a strict lock")
+	assert.Contains(t, err.Error(), "has no checksum")
+
+	lock.SetChecksum("jx-gitops", "9.9.9", plugins.Platform(), "abc")
+	_, err = plugins.ResolvePlugins(dir, lock)
+	assert.NoError(t, err)
+}
+
+func TestFindVersionStreamDirFromKptfile(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv(plugins.VersionStreamDirEnvVar, "")
+
+	// a versionStream dir is only detected if it has a Kptfile with an upstream git repository
+	require.NoError(t, os.MkdirAll("versionStream", 0o700))
+	found, err := plugins.FindVersionStreamDir("")
+	require.NoError(t, err)
+	assert.Empty(t, found)
+
+	err = os.WriteFile(filepath.Join("versionStream", "Kptfile"), []byte(`apiVersion: kpt.dev/v1
+kind: Kptfile
+upstream:
+  type: git
+  git:
+    repo: https://github.com/jenkins-x/jx3-versions
+    directory: /
+    ref: master
+`), 0o600)
+	require.NoError(t, err)
+	upstream, err := plugins.VersionStreamUpstream("versionStream")
+	require.NoError(t, err)
+	assert.Equal(t, "https://github.com/jenkins-x/jx3-versions", upstream)
+
+	found, err = plugins.FindVersionStreamDir("")
+	require.NoError(t, err)
+	assert.Equal(t, "versionStream", found)
+
+	// the detected version stream may come from any cloned repository so its versions must be locked
+	t.Cleanup(func() {
+		plugins.SetPlugins(plugins.DefaultPlugins)
+	})
+	err = os.MkdirAll(filepath.Join("versionStream", "packages"), 0o700)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join("versionStream", "packages", "jx-gitops.yml"), []byte("version: 9.9.9\n"), 0o600)
+	require.NoError(t, err)
+	lockFile := filepath.Join(t.TempDir(), "plugins.lock")
+	t.Setenv(plugins.LockFileEnvVar, lockFile)
+	err = plugins.UseVersionStream("")
+	require.Error(t, err, "should not trust unlocked versions of a detected version stream")
+	assert.Contains(t, err.Error(), "has no checksum")
+
+	lock := &plugins.Lock{Path: lockFile}
+	lock.SetChecksum("jx-gitops", "9.9.9", plugins.Platform(), "abc")
+	require.NoError(t, lock.Save())
+	require.NoError(t, plugins.UseVersionStream(""))
+	assert.Equal(t, "9.9.9", plugins.PluginMap["jx-gitops"].Spec.Version)
+}
+
+func TestUseVersionStream(t *testing.T) {
+	t.Cleanup(func() {
+		plugins.SetPlugins(plugins.DefaultPlugins)
+	})
+
+	dir := createVersionStream(t, map[string]string{"jx-gitops": "9.9.9"})
+	t.Setenv(plugins.VersionStreamDirEnvVar, dir)
+	t.Setenv(plugins.LockFileEnvVar, filepath.Join(t.TempDir(), "plugins.lock"))
+
+	require.NoError(t, plugins.UseVersionStream(""))
+	assert.Equal(t, "9.9.9", plugins.PluginMap["jx-gitops"].Spec.Version)
+	assert.Equal(t, plugins.GitOpsVersion, plugins.DefaultPlugins[3].Spec.Version, "should not modify the defaults")
+
+	t.Setenv(plugins.VersionStreamDirEnvVar, filepath.Join(dir, "does-not-exist"))
+	require.Error(t, plugins.UseVersionStream(""))
+
+	t.Setenv(plugins.VersionStreamDirEnvVar, "")
+	require.NoError(t, plugins.UseVersionStream(""))
+	assert.Equal(t, plugins.GitOpsVersion, plugins.PluginMap["jx-gitops"].Spec.Version, "should use the defaults without a version stream")
+}
@@ -0,0 +1,136 @@
+package signature
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jenkins-x/jx/pkg/config"
+)
+
+const (
+	// Suffix the suffix appended to the URL of an archive to find its detached signature
+	Suffix = ".sig"
+
+	// KeysDirName the name of the directory in the jx home dir containing additional trusted public keys
+	KeysDirName = "keys"
+
+	// TrustedKeysEnvVar the environment variable containing a list of additional trusted public key files
+	// separated by the OS path list separator
+	TrustedKeysEnvVar = "JX_TRUSTED_KEYS"
+
+	// InsecureSkipVerifyEnvVar the environment variable which disables signature verification if set to true
+	InsecureSkipVerifyEnvVar = "JX_INSECURE_SKIP_VERIFY"
+
+	// DefaultPublicKey the public key used to sign jx releases which is the jx.pub file in the root of the repository
+	DefaultPublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEofm/QFAG18JrP+/t0gBTTwK+nstS
+ZIUiIMfC2HnLPndiThh+HxH9aSkrVZed/yK/0gbvmthxbe07dMre2VA/zw==
+-----END PUBLIC KEY-----
+`
+)
+
+// Verifier verifies cosign style detached signatures of downloaded blobs
+type Verifier struct {
+	// Keys the trusted public keys
+	Keys []*ecdsa.PublicKey
+}
+
+// NewVerifier creates a verifier trusting the default public key, any keys in the keys directory of the
+// jx home dir and any key files in the $JX_TRUSTED_KEYS environment variable
+func NewVerifier() (*Verifier, error) {
+	key, err := ParsePublicKey([]byte(DefaultPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse default public key: %w", err)
+	}
+	v := &Verifier{Keys: []*ecdsa.PublicKey{key}}
+
+	dir, err := config.HomeDir()
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, KeysDirName, "*.pub"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find public keys in %s: %w", dir, err)
+	}
+	for _, path := range filepath.SplitList(os.Getenv(TrustedKeysEnvVar)) {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	for _, path := range paths {
+		err = v.AddKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// InsecureSkipVerify returns true if signature verification has been disabled via the environment
+func InsecureSkipVerify() bool {
+	return strings.EqualFold(os.Getenv(InsecureSkipVerifyEnvVar), "true")
+}
+
+// VerifyEnabled returns true unless signature verification has been disabled via the environment
+func VerifyEnabled() bool {
+	return !InsecureSkipVerify()
+}
+
+// AddKeyFile adds the PEM encoded public key in the given file to the trusted keys
+func (v *Verifier) AddKeyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read public key %s: %w", path, err)
+	}
+	key, err := ParsePublicKey(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	v.Keys = append(v.Keys, key)
+	return nil
+}
+
+// ParsePublicKey parses a PEM encoded ECDSA public key
+func ParsePublicKey(data []byte) (*ecdsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM encoded public key found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T, only ECDSA keys are supported", pub)
+	}
+	return key, nil
+}
+
+// Verify verifies the signature of the data was created by one of the trusted keys.
+//
+// The signature can either be base64 encoded as created by 'cosign sign-blob' or the raw ASN.1 signature
+func (v *Verifier) Verify(data, sig []byte) error {
+	if len(v.Keys) == 0 {
+		return errors.New("there are no trusted public keys to verify the signature")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err == nil {
+		sig = decoded
+	}
+	digest := sha256.Sum256(data)
+	for _, key := range v.Keys {
+		if ecdsa.VerifyASN1(key, digest[:], sig) {
+			return nil
+		}
+	}
+	return errors.New("the signature was not created by any of the trusted public keys")
+}
@@ -0,0 +1,51 @@
+package signature_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/jenkins-x/jx/pkg/signature"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPublicKey(t *testing.T) {
+	t.Parallel()
+
+	_, err := signature.ParsePublicKey([]byte(signature.DefaultPublicKey))
+	assert.NoError(t, err, "failed to parse the default public key")
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	data := []byte("some release archive")
+	digest := sha256.Sum256(data)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+
+	v := &signature.Verifier{Keys: []*ecdsa.PublicKey{&otherKey.PublicKey, &key.PublicKey}}
+	assert.NoError(t, v.Verify(data, sig), "should verify a raw signature")
+	assert.NoError(t, v.Verify(data, []byte(base64.StdEncoding.EncodeToString(sig)+"\n")), "should verify a base64 signature")
+	assert.Error(t, v.Verify([]byte("tampered"), sig), "should not verify tampered data")
+
+	v = &signature.Verifier{Keys: []*ecdsa.PublicKey{&otherKey.PublicKey}}
+	assert.Error(t, v.Verify(data, sig), "should not verify a signature from an untrusted key")
+}
+
+func TestVerifyEnabled(t *testing.T) {
+	t.Setenv(signature.InsecureSkipVerifyEnvVar, "")
+	assert.True(t, signature.VerifyEnabled(), "verification should be enabled by default")
+
+	t.Setenv(signature.InsecureSkipVerifyEnvVar, "true")
+	assert.False(t, signature.VerifyEnabled(), "verification should be disabled via the environment")
+}